@@ -0,0 +1,229 @@
+package nx
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"gopkg.in/pipe.v2"
+)
+
+// ErrAwkNext may be returned by an AwkRule action to skip the remaining rules
+// for the current record, like the awk next statement.
+var ErrAwkNext = errors.New("nx: awk next")
+
+// ErrAwkExit may be returned by an AwkRule action or AwkSpec.Begin to stop
+// reading records, like the awk exit statement.  The End hook still runs.
+var ErrAwkExit = errors.New("nx: awk exit")
+
+// AwkSpec describes the program run by Awk.
+type AwkSpec struct {
+	// FS separates fields.  If FS is empty fields are separated by runs of
+	// spaces and tabs and leading and trailing blanks are ignored.
+	FS string
+
+	// OFS separates the values written by Record.Print and the fields of
+	// records modified with Record.SetField.  If OFS is empty a single space
+	// is used.
+	OFS string
+
+	// Begin, if not nil, is called before any record is read with a Record
+	// holding no fields.
+	Begin func(r *Record) error
+
+	// Rules are applied in order to each record.
+	Rules []AwkRule
+
+	// End, if not nil, is called after the last record is processed with the
+	// last Record read, or an empty one if there were none.  NR holds the
+	// number of records read.
+	End func(r *Record) error
+
+	// Lines determines how input is split into records.  Output records are
+	// terminated by Lines.Delim, or '\n' if it is empty.
+	Lines LineOptions
+}
+
+// AwkRule is a pattern→action pair of an AwkSpec.
+type AwkRule struct {
+	// Pattern selects the records the rule applies to.  If Pattern is nil the
+	// rule applies to every record.
+	Pattern func(r *Record) bool
+
+	// Action is called for each selected record.  If Action is nil the
+	// record is printed.
+	Action func(r *Record) error
+}
+
+// AwkRegexp returns an AwkRule pattern selecting records whose text matches
+// re, like the awk pattern /re/.
+func AwkRegexp(re *regexp.Regexp) func(r *Record) bool {
+	return func(r *Record) bool {
+		return re.MatchString(r.Text)
+	}
+}
+
+// Record is a record processed by Awk.  Records should be modified using
+// SetField and SetText, which keep Text, Fields and NF consistent.  A Record
+// and its Fields are reused for each record read.
+type Record struct {
+	NR     int      // the record number, starting from 1
+	NF     int      // the number of fields
+	Text   string   // the record, without its terminator, like $0
+	Fields []string // the fields of the record
+
+	spec *AwkSpec
+	fopt *FieldOptions
+	w    *bufio.Writer
+	ors  []byte
+}
+
+// Field returns field i of r, starting from 1, like $i.  Field 0 is the
+// entire record.  Fields beyond NF are empty.
+func (r *Record) Field(i int) string {
+	switch {
+	case i == 0:
+		return r.Text
+	case i < 0 || i > r.NF:
+		return ""
+	}
+	return r.Fields[i-1]
+}
+
+// SetField sets field i of r to v and rebuilds Text by joining the fields
+// with OFS.  Setting field 0 is equivalent to SetText.  Setting a field
+// beyond NF adds empty fields.
+func (r *Record) SetField(i int, v string) {
+	if i == 0 {
+		r.SetText(v)
+		return
+	}
+	if i < 0 {
+		return
+	}
+	for len(r.Fields) < i {
+		r.Fields = append(r.Fields, "")
+	}
+	r.Fields[i-1] = v
+	r.NF = len(r.Fields)
+	r.Text = strings.Join(r.Fields, r.ofs())
+}
+
+// SetText sets the text of r and splits it into fields again.
+func (r *Record) SetText(s string) {
+	r.Text = s
+	r.Fields = r.Fields[:0]
+	for _, field := range r.fopt.split([]byte(s)) {
+		r.Fields = append(r.Fields, string(field))
+	}
+	r.NF = len(r.Fields)
+}
+
+func (r *Record) ofs() string {
+	if r.spec.OFS == "" {
+		return " "
+	}
+	return r.spec.OFS
+}
+
+// Print writes values to stdout separated by OFS and followed by a record
+// terminator, like the awk print statement.  With no values Print writes
+// Text.
+func (r *Record) Print(values ...interface{}) error {
+	if len(values) == 0 {
+		r.w.WriteString(r.Text)
+	}
+	for i, v := range values {
+		if i > 0 {
+			r.w.WriteString(r.ofs())
+		}
+		fmt.Fprint(r.w, v)
+	}
+	_, err := r.w.Write(r.ors)
+	return err
+}
+
+// Printf writes values to stdout formatted according to format, like the
+// awk printf statement.  No record terminator is written.
+func (r *Record) Printf(format string, values ...interface{}) error {
+	_, err := fmt.Fprintf(r.w, format, values...)
+	return err
+}
+
+// Awk returns a pipe.Pipe that runs spec over the records read from stdin,
+// like awk(1).  Each record is split into fields and given to the action of
+// every rule whose pattern selects it.  Output is written by actions with
+// Record.Print and Record.Printf.  A nil spec is equivalent to a zero
+// AwkSpec.
+func Awk(spec *AwkSpec) pipe.Pipe {
+	if spec == nil {
+		spec = &AwkSpec{}
+	}
+	s := *spec
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		r := &Record{
+			spec: &s,
+			fopt: &FieldOptions{Delim: s.FS},
+			w:    bufio.NewWriter(p.Stdout),
+			ors:  s.Lines.Delim,
+		}
+		if len(r.ors) == 0 {
+			r.ors = []byte{'\n'}
+		}
+		err := awk(r, p.Stdin)
+		if err == ErrAwkExit {
+			err = nil
+		}
+		if err == nil && s.End != nil {
+			err = s.End(r)
+			if err == ErrAwkExit {
+				err = nil
+			}
+		}
+		if err != nil {
+			return err
+		}
+		return r.w.Flush()
+	})
+}
+
+func awk(r *Record, stdin io.Reader) error {
+	spec := r.spec
+	if spec.Begin != nil {
+		err := spec.Begin(r)
+		if err != nil {
+			return err
+		}
+	}
+	lr := newLineReader(stdin, spec.Lines)
+	for {
+		line, _, err := lr.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		r.NR++
+		r.SetText(string(line))
+		for _, rule := range spec.Rules {
+			if rule.Pattern != nil && !rule.Pattern(r) {
+				continue
+			}
+			if rule.Action == nil {
+				err = r.Print()
+			} else {
+				err = rule.Action(r)
+			}
+			if err == ErrAwkNext {
+				break
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package nx
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"gopkg.in/pipe.v2"
+)
+
+func TestAwk(t *testing.T) {
+	in := "alice 3\nbob 5\n# comment\ncarol 2\n"
+	comment := AwkRegexp(regexp.MustCompile(`^#`))
+	var sum int
+	spec := &AwkSpec{
+		OFS: "=",
+		Begin: func(r *Record) error {
+			return r.Print("name", "n")
+		},
+		Rules: []AwkRule{
+			{Pattern: comment, Action: func(r *Record) error { return ErrAwkNext }},
+			{Action: func(r *Record) error {
+				n, err := strconv.Atoi(r.Field(2))
+				if err != nil {
+					return err
+				}
+				sum += n
+				return r.Print(r.Field(1), r.Field(2))
+			}},
+			{Pattern: func(r *Record) bool { return r.NR == 2 }},
+		},
+		End: func(r *Record) error {
+			return r.Printf("%d records, %d fields, total %d\n", r.NR, r.NF, sum)
+		},
+	}
+	out, err := runPipe(Awk(spec), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "name=n\nalice=3\nbob=5\nbob 5\ncarol=2\n4 records, 2 fields, total 10\n"
+	if out != expect {
+		t.Errorf("output %q (expected %q)", out, expect)
+	}
+}
+
+func TestAwkRecord(t *testing.T) {
+	spec := &AwkSpec{
+		FS:  ",",
+		OFS: ";",
+		Rules: []AwkRule{{Action: func(r *Record) error {
+			r.SetField(2, "X")
+			r.SetField(4, "new")
+			return nil
+		}}, {}},
+	}
+	out, err := runPipe(Awk(spec), "a,b,c\nd\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "a;X;c;new\nd;X;;new\n" {
+		t.Errorf("output %q", out)
+	}
+
+	spec = &AwkSpec{Rules: []AwkRule{{Action: func(r *Record) error {
+		r.SetText(r.Field(0) + " z")
+		return r.Print(r.NF, r.Field(-1), r.Field(r.NF), r.Field(9))
+	}}}}
+	out, err = runPipe(Awk(spec), "  x  y \n")
+	if err != nil || out != "3  z \n" {
+		t.Errorf("output %q, error %v", out, err)
+	}
+}
+
+func TestAwkExit(t *testing.T) {
+	fail := errors.New("fail")
+	var ended bool
+	spec := &AwkSpec{
+		Rules: []AwkRule{{Action: func(r *Record) error {
+			if r.NR == 2 {
+				return ErrAwkExit
+			}
+			return r.Print()
+		}}},
+		End: func(r *Record) error {
+			ended = true
+			return r.Print("end", r.NR)
+		},
+	}
+	out, err := runPipe(Awk(spec), "a\nb\nc\n")
+	if err != nil || out != "a\nend 2\n" || !ended {
+		t.Errorf("output %q, error %v", out, err)
+	}
+
+	spec.Rules[0].Action = func(r *Record) error { return fail }
+	if _, err := runPipe(Awk(spec), "a\n"); err != fail {
+		t.Errorf("error %v (expected %v)", err, fail)
+	}
+	if out, err := runPipe(Awk(nil), "a\n"); err != nil || out != "" {
+		t.Errorf("output %q, error %v", out, err)
+	}
+}
+
+func TestAwk_line(t *testing.T) {
+	second := &AwkSpec{Rules: []AwkRule{{Action: func(r *Record) error { return r.Print(r.Field(2)) }}}}
+	out, err := runPipe(pipe.Line(pipe.Print("a b\nc d\n"), Awk(second), Tac(nil)), "")
+	if err != nil || out != "d\nb\n" {
+		t.Errorf("output %q, error %v", out, err)
+	}
+}
@@ -0,0 +1,99 @@
+package nx
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"gopkg.in/pipe.v2"
+)
+
+// CountLines returns a pipe.Pipe that emits the number of lines read from
+// stdin to stdout, like `wc -l`.  As with wc, a final line lacking a
+// terminator is not counted.  Lines are split according to opts, see
+// LineOptions.
+func CountLines(opts ...LineOptions) pipe.Pipe {
+	opt := lineOptions(opts)
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		var n int64
+		if len(opt.Delim) <= 1 && opt.Regexp == nil && opt.MaxLen <= 0 {
+			delim := []byte{'\n'}
+			if len(opt.Delim) == 1 {
+				delim = opt.Delim
+			}
+			buf := make([]byte, 32<<10)
+			for {
+				k, err := p.Stdin.Read(buf)
+				n += int64(bytes.Count(buf[:k], delim))
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					return err
+				}
+			}
+		} else {
+			r := newLineReader(p.Stdin, opt)
+			for {
+				_, term, err := r.next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					return err
+				}
+				if len(term) > 0 {
+					n++
+				}
+			}
+		}
+		_, err := fmt.Fprintln(p.Stdout, n)
+		return err
+	})
+}
+
+// CountBytes returns a pipe.Pipe that emits the number of bytes read from
+// stdin to stdout, like `wc -c`.
+func CountBytes() pipe.Pipe {
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		n, err := io.Copy(ioutil.Discard, p.Stdin)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(p.Stdout, n)
+		return err
+	})
+}
+
+// CountWords returns a pipe.Pipe that emits the number of words read from
+// stdin to stdout, like `wc -w`.  Words are separated by ASCII white space.
+func CountWords() pipe.Pipe {
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		var n int64
+		inWord := false
+		buf := make([]byte, 32<<10)
+		for {
+			k, err := p.Stdin.Read(buf)
+			for _, c := range buf[:k] {
+				switch c {
+				case ' ', '\t', '\n', '\v', '\f', '\r':
+					inWord = false
+				default:
+					if !inWord {
+						n++
+					}
+					inWord = true
+				}
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+		}
+		_, err := fmt.Fprintln(p.Stdout, n)
+		return err
+	})
+}
@@ -0,0 +1,302 @@
+package nx
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"gopkg.in/pipe.v2"
+)
+
+// ErrDiffer is returned by DiffWith when DiffOptions.Fail is set and its
+// inputs differ.
+var ErrDiffer = errors.New("nx: inputs differ")
+
+// DiffOptions configures DiffWith.
+type DiffOptions struct {
+	// Context is the number of unchanged lines surrounding each change.
+	Context int
+
+	// LabelA and LabelB name stdin and the other input in the diff header.
+	// If they are empty "a" and "b" are used.
+	LabelA string
+	LabelB string
+
+	// Fail causes ErrDiffer to be returned, after the diff is written, if
+	// the inputs differ.
+	Fail bool
+}
+
+// Diff returns a pipe.Pipe that emits a unified diff, with 3 lines of
+// context, from stdin to the output of other, like `diff -u`.  Nothing is
+// emitted if the inputs are identical.  Other is run concurrently with no
+// input; use pipe.ReadFile to compare stdin to a file.
+func Diff(other pipe.Pipe) pipe.Pipe {
+	return DiffWith(other, &DiffOptions{Context: 3})
+}
+
+// DiffWith returns a pipe.Pipe that emits a unified diff from stdin to the
+// output of other as described by opts, see Diff.  A nil opts is equivalent
+// to a zero DiffOptions.  Both inputs are held in memory.
+func DiffWith(other pipe.Pipe, opts *DiffOptions) pipe.Pipe {
+	if opts == nil {
+		opts = &DiffOptions{}
+	}
+	opt := *opts
+	if opt.LabelA == "" {
+		opt.LabelA = "a"
+	}
+	if opt.LabelB == "" {
+		opt.LabelB = "b"
+	}
+	if opt.Context < 0 {
+		opt.Context = 0
+	}
+	return groupTaskFunc(func(p *pipe.State, g *stateGroup) error {
+		var b pipe.OutputBuffer
+		var berr error
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			berr = g.run(other, newSubState(p, nil, &b, p.Stderr))
+		}()
+		a, err := ioutil.ReadAll(p.Stdin)
+		wg.Wait()
+		if err != nil {
+			return err
+		}
+		if berr != nil {
+			return berr
+		}
+		if bytes.Equal(a, b.Bytes()) {
+			return nil
+		}
+		w := bufio.NewWriter(p.Stdout)
+		err = writeDiff(w, splitDiffLines(a), splitDiffLines(b.Bytes()), &opt)
+		if err == nil {
+			err = w.Flush()
+		}
+		if err != nil {
+			return err
+		}
+		if opt.Fail {
+			return ErrDiffer
+		}
+		return nil
+	})
+}
+
+// splitDiffLines splits b into lines including their terminators.
+func splitDiffLines(b []byte) []string {
+	lines := strings.SplitAfter(string(b), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffOp is an element of an edit script.
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	a, b int  // indices of the lines in a and b
+}
+
+// diffLines returns an edit script transforming a into b.
+func diffLines(a, b []string) []diffOp {
+	ids := make(map[string]int)
+	intern := func(lines []string) []int {
+		x := make([]int, len(lines))
+		for i, line := range lines {
+			id, ok := ids[line]
+			if !ok {
+				id = len(ids)
+				ids[line] = id
+			}
+			x[i] = id
+		}
+		return x
+	}
+	x, y := intern(a), intern(b)
+
+	var prefix, suffix int
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		prefix++
+	}
+	for suffix < len(x)-prefix && suffix < len(y)-prefix && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+
+	var ops []diffOp
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, diffOp{' ', i, i})
+	}
+	for _, op := range myers(x[prefix:len(x)-suffix], y[prefix:len(y)-suffix]) {
+		op.a += prefix
+		op.b += prefix
+		ops = append(ops, op)
+	}
+	for i := suffix; i > 0; i-- {
+		ops = append(ops, diffOp{' ', len(x) - i, len(y) - i})
+	}
+	return ops
+}
+
+// myers returns a shortest edit script transforming a into b using the
+// greedy algorithm of Myers, "An O(ND) Difference Algorithm and Its
+// Variations".
+func myers(a, b []int) []diffOp {
+	n, m := len(a), len(b)
+	max := n + m
+	off := max + 1
+	v := make([]int, 2*max+3)
+	var trace [][]int // trace[d] holds v[-d..d] before round d
+	var d int
+search:
+	for d = 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v[off-d:off+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[off+k-1] < v[off+k+1] {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// backtrack from the end, collecting operations in reverse
+	var ops []diffOp
+	x, y := n, m
+	for ; d > 0; d-- {
+		vd := trace[d]
+		at := func(k int) int { return vd[k+d] }
+		k := x - y
+		var prevK int
+		if k == -d || k != d && at(k-1) < at(k+1) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, diffOp{' ', x, y})
+		}
+		if x == prevX {
+			ops = append(ops, diffOp{'+', x, prevY})
+		} else {
+			ops = append(ops, diffOp{'-', prevX, y})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		ops = append(ops, diffOp{' ', x, y})
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// writeDiff writes the unified diff from a to b.
+func writeDiff(w *bufio.Writer, a, b []string, opt *DiffOptions) error {
+	ops := diffLines(a, b)
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", opt.LabelA, opt.LabelB)
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		// extend the hunk while changes are separated by at most twice the
+		// context.
+		start := i - opt.Context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			next := end
+			for next < len(ops) && ops[next].kind == ' ' {
+				next++
+			}
+			if next == len(ops) || next-end > 2*opt.Context {
+				break
+			}
+			end = next
+		}
+		stop := end + opt.Context
+		if stop > len(ops) {
+			stop = len(ops)
+		}
+		err := writeHunk(w, ops[start:stop], a, b)
+		if err != nil {
+			return err
+		}
+		i = stop
+	}
+	return nil
+}
+
+func writeHunk(w *bufio.Writer, ops []diffOp, a, b []string) error {
+	var na, nb int
+	for _, op := range ops {
+		if op.kind != '+' {
+			na++
+		}
+		if op.kind != '-' {
+			nb++
+		}
+	}
+	_, err := fmt.Fprintf(w, "@@ -%s +%s @@\n", hunkRange(ops[0].a, na), hunkRange(ops[0].b, nb))
+	for _, op := range ops {
+		var line string
+		if op.kind == '+' {
+			line = b[op.b]
+		} else {
+			line = a[op.a]
+		}
+		w.WriteByte(op.kind)
+		_, err = w.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			_, err = w.WriteString("\n\\ No newline at end of file\n")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// hunkRange formats the range of n lines starting at index i in the style of
+// `diff -u`.
+func hunkRange(i, n int) string {
+	switch n {
+	case 0:
+		return fmt.Sprintf("%d,0", i)
+	case 1:
+		return fmt.Sprint(i + 1)
+	}
+	return fmt.Sprintf("%d,%d", i+1, n)
+}
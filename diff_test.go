@@ -0,0 +1,113 @@
+package nx
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"gopkg.in/pipe.v2"
+)
+
+func TestDiff(t *testing.T) {
+	for i, test := range []struct {
+		a, b    string
+		context int
+		out     string
+	}{
+		{"a\nb\n", "a\nb\n", 3, ""},
+		{"a\nb\nc\n", "a\nx\nc\n", 3, "--- a\n+++ b\n@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n"},
+		{"a\nb\nc\n", "a\nx\nc\n", 0, "--- a\n+++ b\n@@ -2 +2 @@\n-b\n+x\n"},
+		{"", "a\n", 3, "--- a\n+++ b\n@@ -0,0 +1 @@\n+a\n"},
+		{"a\nb\n", "a\n", 3, "--- a\n+++ b\n@@ -1,2 +1 @@\n a\n-b\n"},
+		{"a\n", "a", 3, "--- a\n+++ b\n@@ -1 +1 @@\n-a\n+a\n\\ No newline at end of file\n"},
+		{
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			"x\n2\n3\n4\n5\n6\n7\n8\ny\n",
+			1,
+			"--- a\n+++ b\n@@ -1,2 +1,2 @@\n-1\n+x\n 2\n@@ -8,2 +8,2 @@\n 8\n-9\n+y\n",
+		},
+		{
+			"1\n2\n3\n4\n5\n",
+			"x\n2\n3\n4\ny\n",
+			2,
+			"--- a\n+++ b\n@@ -1,5 +1,5 @@\n-1\n+x\n 2\n 3\n 4\n-5\n+y\n",
+		},
+	} {
+		out, err := runPipe(DiffWith(pipe.Print(test.b), &DiffOptions{Context: test.context}), test.a)
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+		}
+		if out != test.out {
+			t.Errorf("test %d: output %q (expected %q)", i, out, test.out)
+		}
+	}
+
+	_, err := runPipe(DiffWith(pipe.Print("b\n"), &DiffOptions{Fail: true}), "a\n")
+	if err != ErrDiffer {
+		t.Errorf("error %v (expected %v)", err, ErrDiffer)
+	}
+
+	out, err := runPipe(pipe.Line(Tr("a-z", "A-Z", nil), Diff(pipe.Exec("printf", "A\\nc\\n"))), "a\nb\n")
+	if err != nil || out != "--- a\n+++ b\n@@ -1,2 +1,2 @@\n A\n-B\n+c\n" {
+		t.Errorf("output %q, error %v", out, err)
+	}
+}
+
+// lcsLength returns the length of the longest common subsequence of a and b.
+func lcsLength(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for i := range a {
+		for j := range b {
+			switch {
+			case a[i] == b[j]:
+				cur[j+1] = prev[j] + 1
+			case prev[j+1] > cur[j]:
+				cur[j+1] = prev[j+1]
+			default:
+				cur[j+1] = cur[j]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func TestDiffLinesMinimal(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	gen := func() []string {
+		lines := make([]string, rng.Intn(30))
+		for i := range lines {
+			lines[i] = string(rune('a'+rng.Intn(4))) + "\n"
+		}
+		return lines
+	}
+	for i := 0; i < 500; i++ {
+		a, b := gen(), gen()
+		ops := diffLines(a, b)
+		var ra, rb []string
+		var edits int
+		for _, op := range ops {
+			switch op.kind {
+			case ' ':
+				ra = append(ra, a[op.a])
+				rb = append(rb, b[op.b])
+				if a[op.a] != b[op.b] {
+					t.Fatalf("unequal context %q %q", a[op.a], b[op.b])
+				}
+			case '-':
+				ra = append(ra, a[op.a])
+				edits++
+			case '+':
+				rb = append(rb, b[op.b])
+				edits++
+			}
+		}
+		if strings.Join(ra, "") != strings.Join(a, "") || strings.Join(rb, "") != strings.Join(b, "") {
+			t.Fatalf("script does not reproduce inputs %q %q", a, b)
+		}
+		if expect := len(a) + len(b) - 2*lcsLength(a, b); edits != expect {
+			t.Fatalf("%d edits (expected %d) for %q %q", edits, expect, a, b)
+		}
+	}
+}
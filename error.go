@@ -1,7 +1,10 @@
 package nx
 
 import (
+	"bufio"
 	"fmt"
+	"io"
+	"sync"
 
 	"gopkg.in/pipe.v2"
 )
@@ -15,3 +18,189 @@ func Error(err error) pipe.Pipe {
 func Errorf(format string, v ...interface{}) pipe.Pipe {
 	return Error(fmt.Errorf(format, v...))
 }
+
+// Catch returns a pipe.Pipe that runs p and, if p fails, runs the pipe.Pipe
+// returned by calling handler with the error.  The handler pipe runs with the
+// same input and output as p, so it reads any input p did not consume.  If
+// handler returns nil the error is discarded.
+//
+// Like the other combinators in this file, Catch runs p along with its tasks
+// from a task of its own, so errors are seen when they occur rather than only
+// while the pipe is being set up.  Changes p makes to the directory or
+// environment of its state do not affect the pipes following Catch.
+func Catch(p pipe.Pipe, handler func(err error) pipe.Pipe) pipe.Pipe {
+	return groupTaskFunc(func(s *pipe.State, g *stateGroup) error {
+		err := g.run(p, newSubState(s, s.Stdin, s.Stdout, s.Stderr))
+		if err == nil || err == pipe.ErrKilled {
+			return err
+		}
+		h := handler(err)
+		if h == nil {
+			return nil
+		}
+		return g.run(h, newSubState(s, s.Stdin, s.Stdout, s.Stderr))
+	})
+}
+
+// Ignore returns a pipe.Pipe that runs p and discards any error for which
+// ignore returns true.  If ignore is nil every error is discarded.  As with
+// Catch, pipe.ErrKilled is never discarded, so a killed pipe still fails.
+func Ignore(p pipe.Pipe, ignore func(err error) bool) pipe.Pipe {
+	return groupTaskFunc(func(s *pipe.State, g *stateGroup) error {
+		err := g.run(p, newSubState(s, s.Stdin, s.Stdout, s.Stderr))
+		if err == pipe.ErrKilled {
+			return err
+		}
+		if err != nil && (ignore == nil || ignore(err)) {
+			return nil
+		}
+		return err
+	})
+}
+
+// StageError is an error annotated with the name of the stage of a pipeline
+// that caused it.
+type StageError struct {
+	Stage string
+	Err   error
+}
+
+func (err *StageError) Error() string {
+	return err.Stage + ": " + err.Err.Error()
+}
+
+// Unwrap returns the annotated error, for use with errors.Is and errors.As.
+func (err *StageError) Unwrap() error {
+	return err.Err
+}
+
+// Wrap returns a pipe.Pipe that runs p and annotates any error it returns
+// with stage, as a *StageError.
+func Wrap(p pipe.Pipe, stage string) pipe.Pipe {
+	return groupTaskFunc(func(s *pipe.State, g *stateGroup) error {
+		err := g.run(p, newSubState(s, s.Stdin, s.Stdout, s.Stderr))
+		if err != nil {
+			return &StageError{Stage: stage, Err: err}
+		}
+		return nil
+	})
+}
+
+// Cause returns the error underlying any StageError annotations of err.  A
+// pipe.Errors holding a single error, as returned by pipe.Run, is looked
+// through.
+func Cause(err error) error {
+	for {
+		if errs, ok := err.(pipe.Errors); ok && len(errs) == 1 {
+			err = errs[0]
+			continue
+		}
+		serr, ok := err.(*StageError)
+		if !ok {
+			return err
+		}
+		err = serr.Err
+	}
+}
+
+// AssertionError is returned by Assert when a line does not satisfy its
+// predicate.
+type AssertionError struct {
+	Line int // line number, starting from 1
+	Text string
+}
+
+func (err *AssertionError) Error() string {
+	return fmt.Sprintf("assertion failed on line %d: %q", err.Line, err.Text)
+}
+
+// Assert returns a pipe.Pipe that copies lines read from stdin to stdout and
+// fails with an *AssertionError at the first line for which f returns false.
+// Lines preceding the failing line are emitted.  The line given to f does not
+// include its terminator.
+func Assert(f func(line []byte) bool, opts ...LineOptions) pipe.Pipe {
+	opt := lineOptions(opts)
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		r := newLineReader(p.Stdin, opt)
+		w := bufio.NewWriter(p.Stdout)
+		for n := 1; ; n++ {
+			line, term, err := r.next()
+			if err == io.EOF {
+				return w.Flush()
+			}
+			if err != nil {
+				return err
+			}
+			if !f(line) {
+				err = w.Flush()
+				if err != nil {
+					return err
+				}
+				return &AssertionError{Line: n, Text: string(line)}
+			}
+			_, err = w.Write(line)
+			if err == nil {
+				_, err = w.Write(term)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// StderrError is returned by FailOnStderr when a pipe writes to stderr.
+type StderrError struct {
+	// Output holds the beginning of the output written to stderr.
+	Output []byte
+}
+
+func (err *StderrError) Error() string {
+	return fmt.Sprintf("unexpected output on stderr: %q", err.Output)
+}
+
+// maxStderrError is the amount of stderr output retained by a StderrError.
+const maxStderrError = 512
+
+// FailOnStderr returns a pipe.Pipe that runs p and fails with a *StderrError
+// if p succeeds but writes to stderr, for commands that report problems
+// without a failing exit status.  Output written to stderr is still passed
+// through.  Errors returned by p take precedence.
+func FailOnStderr(p pipe.Pipe) pipe.Pipe {
+	return groupTaskFunc(func(s *pipe.State, g *stateGroup) error {
+		w := &stderrRecorder{w: s.Stderr}
+		err := g.run(p, newSubState(s, s.Stdin, s.Stdout, w))
+		if err != nil {
+			return err
+		}
+		if w.n > 0 {
+			return &StderrError{Output: w.head}
+		}
+		return nil
+	})
+}
+
+// stderrRecorder passes writes through to w while recording their beginning.
+// It may be written to by concurrent tasks.
+type stderrRecorder struct {
+	mu   sync.Mutex
+	w    io.Writer
+	n    int64
+	head []byte
+}
+
+func (r *stderrRecorder) Write(b []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.n += int64(len(b))
+	if k := maxStderrError - len(r.head); k > 0 {
+		if k > len(b) {
+			k = len(b)
+		}
+		r.head = append(r.head, b[:k]...)
+	}
+	if r.w == nil {
+		return len(b), nil
+	}
+	return r.w.Write(b)
+}
@@ -0,0 +1,177 @@
+package nx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/pipe.v2"
+)
+
+func TestCatch(t *testing.T) {
+	fail := errors.New("fail")
+	out, err := runPipe(Catch(Error(fail), func(err error) pipe.Pipe {
+		return pipe.Print("caught " + err.Error())
+	}), "")
+	if err != nil || out != "caught fail" {
+		t.Errorf("output %q, error %v", out, err)
+	}
+	_, err = runPipe(Catch(Error(fail), func(err error) pipe.Pipe { return nil }), "")
+	if err != nil {
+		t.Errorf("error %v", err)
+	}
+	out, err = runPipe(Catch(pipe.Print("ok"), func(err error) pipe.Pipe { return Error(err) }), "")
+	if err != nil || out != "ok" {
+		t.Errorf("output %q, error %v", out, err)
+	}
+	out, err = runPipe(Catch(pipe.Exec("false"), func(err error) pipe.Pipe {
+		return pipe.Print("caught")
+	}), "")
+	if err != nil || out != "caught" {
+		t.Errorf("task error: output %q, error %v", out, err)
+	}
+	notB := func(ln []byte) bool { return !bytes.Equal(ln, []byte("b")) }
+	out, err = runPipe(Catch(pipe.Line(pipe.Print("a\nb\n"), Assert(notB)), func(err error) pipe.Pipe {
+		return pipe.Print(err.Error())
+	}), "")
+	if err != nil || out != "a\n"+(&AssertionError{Line: 2, Text: "b"}).Error() {
+		t.Errorf("line error: output %q, error %v", out, err)
+	}
+}
+
+func TestCatch_kill(t *testing.T) {
+	s := newState(nil, nil)
+	err := Catch(pipe.Exec("sleep", "10"), func(err error) pipe.Pipe {
+		return pipe.Print("caught")
+	})(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- s.RunTasks() }()
+	s.Kill()
+	select {
+	case err = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("kill not forwarded")
+	}
+	if err == nil {
+		t.Errorf("killed pipe succeeded")
+	}
+}
+
+func TestIgnore(t *testing.T) {
+	fail := errors.New("fail")
+	other := errors.New("other")
+	is := func(err error) bool { return err == fail }
+	failTask := pipe.TaskFunc(func(*pipe.State) error { return fail })
+	if _, err := runPipe(Ignore(Error(fail), is), ""); err != nil {
+		t.Errorf("error %v", err)
+	}
+	if _, err := runPipe(Ignore(failTask, is), ""); err != nil {
+		t.Errorf("task error %v", err)
+	}
+	if _, err := runPipe(Ignore(Error(other), is), ""); err != other {
+		t.Errorf("error %v (expected %v)", err, other)
+	}
+	if _, err := runPipe(Ignore(Error(other), nil), ""); err != nil {
+		t.Errorf("error %v", err)
+	}
+}
+
+// TestIgnore_kill checks that Ignore reports being killed when another task
+// fails, rather than discarding pipe.ErrKilled.
+func TestIgnore_kill(t *testing.T) {
+	fail := errors.New("fail")
+	s := newState(nil, nil)
+	err := Ignore(pipe.Exec("sleep", "10"), nil)(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = pipe.TaskFunc(func(*pipe.State) error { return fail })(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- s.RunTasks() }()
+	select {
+	case err = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("kill not forwarded")
+	}
+	errs, _ := err.(pipe.Errors)
+	if len(errs) != 2 || errs[0] != fail || errs[1] != pipe.ErrKilled {
+		t.Errorf("error %v (expected %v and %v)", err, fail, pipe.ErrKilled)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	fail := errors.New("fail")
+	err := pipe.Run(Wrap(Wrap(Error(fail), "inner"), "outer"))
+	if err == nil || err.Error() != "outer: inner: fail" {
+		t.Errorf("error %v", err)
+	}
+	if Cause(err) != fail {
+		t.Errorf("cause %v", Cause(err))
+	}
+	if err := pipe.Run(Wrap(pipe.Print("x"), "stage")); err != nil {
+		t.Errorf("error %v", err)
+	}
+	err = pipe.Run(Wrap(pipe.TaskFunc(func(*pipe.State) error { return fail }), "task"))
+	if err == nil || err.Error() != "task: fail" || Cause(err) != fail {
+		t.Errorf("task error %v", err)
+	}
+	_, err = runPipe(Wrap(Error(&AssertionError{Line: 1}), "stage"), "")
+	var aerr *AssertionError
+	if !errors.As(err, &aerr) || aerr.Line != 1 {
+		t.Errorf("errors.As failed on %v", err)
+	}
+	_, err = runPipe(Wrap(Wrap(Error(fail), "inner"), "outer"), "")
+	if !errors.Is(err, fail) {
+		t.Errorf("errors.Is failed on %v", err)
+	}
+}
+
+func TestAssert(t *testing.T) {
+	digits := func(line []byte) bool { return len(bytes.Trim(line, "0123456789")) == 0 }
+	out, err := runPipe(Assert(digits), "1\n22\n")
+	if err != nil || out != "1\n22\n" {
+		t.Errorf("output %q, error %v", out, err)
+	}
+	out, err = runPipe(Assert(digits), "1\n22\nx3\n4\n")
+	aerr, ok := err.(*AssertionError)
+	if !ok || aerr.Line != 3 || aerr.Text != "x3" {
+		t.Errorf("error %v", err)
+	}
+	if out != "1\n22\n" {
+		t.Errorf("output %q", out)
+	}
+}
+
+func TestFailOnStderr(t *testing.T) {
+	var stderr bytes.Buffer
+	warn := func(s *pipe.State) error {
+		_, err := fmt.Fprint(s.Stderr, strings.Repeat("w", 1000))
+		return err
+	}
+	s := newState(nil, &bytes.Buffer{})
+	s.Stderr = &stderr
+	err := runTimeout(FailOnStderr(warn), s)
+	serr, ok := err.(*StderrError)
+	if !ok || len(serr.Output) != maxStderrError {
+		t.Errorf("error %v", err)
+	}
+	if stderr.Len() != 1000 || s.Stderr != &stderr {
+		t.Errorf("stderr not passed through")
+	}
+	if err := pipe.Run(FailOnStderr(pipe.Print("ok"))); err != nil {
+		t.Errorf("error %v", err)
+	}
+	_, err = runPipe(FailOnStderr(pipe.Exec("sh", "-c", "echo w >&2")), "")
+	if serr, ok := err.(*StderrError); !ok || string(serr.Output) != "w\n" {
+		t.Errorf("task error %v", err)
+	}
+}
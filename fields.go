@@ -0,0 +1,356 @@
+package nx
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/pipe.v2"
+)
+
+// FieldRange selects fields From through To, inclusive.  Fields are numbered
+// starting from 1.  Negative values count backwards from the last field, so
+// -1 is the last field.
+type FieldRange struct {
+	From int
+	To   int
+}
+
+// Field returns a FieldRange selecting only field i.
+func Field(i int) FieldRange {
+	return FieldRange{i, i}
+}
+
+// ParseFieldRanges parses a list of fields in the format accepted by `cut -f`,
+// such as "1,3-5,7-".
+func ParseFieldRanges(s string) ([]FieldRange, error) {
+	var ranges []FieldRange
+	for _, item := range strings.Split(s, ",") {
+		if item == "" || item == "-" {
+			return nil, fmt.Errorf("invalid field range %q", item)
+		}
+		var r FieldRange
+		var err error
+		from, to := item, item
+		if i := strings.Index(item, "-"); i >= 0 {
+			from, to = item[:i], item[i+1:]
+		}
+		if from == "" {
+			r.From = 1
+		} else if r.From, err = strconv.Atoi(from); err != nil || r.From < 1 {
+			return nil, fmt.Errorf("invalid field range %q", item)
+		}
+		if to == "" {
+			r.To = -1
+		} else if r.To, err = strconv.Atoi(to); err != nil || r.To < r.From {
+			return nil, fmt.Errorf("invalid field range %q", item)
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// FieldOptions determines how lines are split into fields.  The zero value
+// splits fields like awk, on runs of spaces and tabs.
+type FieldOptions struct {
+	// Delim separates fields.  If Delim is empty fields are separated by runs
+	// of spaces and tabs and leading and trailing blanks are ignored.
+	Delim string
+
+	// OutputDelim separates fields that are emitted.  If OutputDelim is empty
+	// Delim is used, or a single space if Delim is empty.
+	OutputDelim string
+
+	// Quote, if not zero, is a byte that quotes fields so they may contain
+	// delimiters.  A quote character inside a quoted field is written twice,
+	// as in CSV.  Quoted fields are emitted with their quotes.
+	Quote byte
+
+	// Lines determines how input is split into lines.
+	Lines LineOptions
+}
+
+func fieldOptions(opts []FieldOptions) FieldOptions {
+	if len(opts) == 0 {
+		return FieldOptions{}
+	}
+	return opts[len(opts)-1]
+}
+
+func (opt *FieldOptions) outputDelim() []byte {
+	switch {
+	case opt.OutputDelim != "":
+		return []byte(opt.OutputDelim)
+	case opt.Delim != "":
+		return []byte(opt.Delim)
+	}
+	return []byte{' '}
+}
+
+// split returns the fields of line.
+func (opt *FieldOptions) split(line []byte) [][]byte {
+	if opt.Delim == "" {
+		return opt.splitBlanks(line)
+	}
+	delim := []byte(opt.Delim)
+	var fields [][]byte
+	for {
+		end := opt.fieldEnd(line, delim)
+		if end < 0 {
+			return append(fields, line)
+		}
+		fields = append(fields, line[:end])
+		line = line[end+len(delim):]
+	}
+}
+
+// fieldEnd returns the index of the delimiter terminating the first field in
+// line or -1 if the field is not terminated.
+func (opt *FieldOptions) fieldEnd(line, delim []byte) int {
+	var i int
+	if opt.Quote != 0 && len(line) > 0 && line[0] == opt.Quote {
+		i = quotedEnd(line, opt.Quote)
+	}
+	j := bytes.Index(line[i:], delim)
+	if j < 0 {
+		return -1
+	}
+	return i + j
+}
+
+// quotedEnd returns the index following the closing quote of the quoted
+// field at the start of line, or len(line) if the field is not closed.
+func quotedEnd(line []byte, quote byte) int {
+	for i := 1; i < len(line); i++ {
+		if line[i] != quote {
+			continue
+		}
+		if i+1 < len(line) && line[i+1] == quote {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(line)
+}
+
+func (opt *FieldOptions) splitBlanks(line []byte) [][]byte {
+	isBlank := func(c byte) bool { return c == ' ' || c == '\t' }
+	var fields [][]byte
+	for {
+		for len(line) > 0 && isBlank(line[0]) {
+			line = line[1:]
+		}
+		if len(line) == 0 {
+			return fields
+		}
+		var i int
+		if opt.Quote != 0 && line[0] == opt.Quote {
+			i = quotedEnd(line, opt.Quote)
+		}
+		for i < len(line) && !isBlank(line[i]) {
+			i++
+		}
+		fields = append(fields, line[:i])
+		line = line[i:]
+	}
+}
+
+// selectFields appends the fields selected by ranges to dst.
+func selectFields(dst, fields [][]byte, ranges []FieldRange) [][]byte {
+	n := len(fields)
+	index := func(i int) int {
+		if i < 0 {
+			return n + 1 + i
+		}
+		return i
+	}
+	for _, r := range ranges {
+		from, to := index(r.From), index(r.To)
+		if from < 1 {
+			from = 1
+		}
+		if to > n {
+			to = n
+		}
+		for i := from; i <= to; i++ {
+			dst = append(dst, fields[i-1])
+		}
+	}
+	return dst
+}
+
+// Columns returns a pipe.Pipe that emits the fields selected by ranges from
+// each line read from stdin to stdout.  Fields are emitted in the order
+// ranges are given, allowing columns to be reordered or repeated.  Selected
+// fields that a line lacks are omitted.
+func Columns(opt FieldOptions, ranges ...FieldRange) pipe.Pipe {
+	odelim := opt.outputDelim()
+	return func(p *pipe.State) error {
+		var selected [][]byte
+		return Replace(func(line []byte) []byte {
+			selected = selectFields(selected[:0], opt.split(line), ranges)
+			return bytes.Join(selected, odelim)
+		}, opt.Lines)(p)
+	}
+}
+
+// Cut returns a pipe.Pipe that emits the fields selected by ranges from each
+// line read from stdin to stdout, like `cut -d delim -f`.  Fields are
+// separated by delim in input and output.  Unlike cut(1) fields are emitted
+// in the order ranges are given.
+func Cut(delim string, ranges ...FieldRange) pipe.Pipe {
+	return Columns(FieldOptions{Delim: delim}, ranges...)
+}
+
+// Fields returns a pipe.Pipe that emits the fields with the given indices from
+// each line read from stdin to stdout, like `awk '{print $i, $j}'`.  Fields are
+// separated by runs of spaces and tabs and are emitted separated by a single
+// space.  Negative indices count backwards from the last field.
+func Fields(indices ...int) pipe.Pipe {
+	ranges := make([]FieldRange, len(indices))
+	for i, index := range indices {
+		ranges[i] = Field(index)
+	}
+	return Columns(FieldOptions{}, ranges...)
+}
+
+// Join returns a pipe.Pipe that joins the lines read from stdin with the lines
+// of the file at otherPath, relative to the pipe.State Dir, like join(1).  Each
+// pair of lines where field keyA of the line from stdin equals field keyB of
+// the line from the file produces a line containing the key, the other fields
+// from stdin and the other fields from the file.  Both inputs must be sorted
+// lexically by their key field.  Lines without a match are omitted.
+func Join(keyA, keyB int, otherPath string, opts ...FieldOptions) pipe.Pipe {
+	opt := fieldOptions(opts)
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		f, err := os.Open(p.Path(otherPath))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		a := &joinReader{r: newLineReader(p.Stdin, opt.Lines), key: keyA, opt: &opt}
+		b := &joinReader{r: newLineReader(f, opt.Lines), key: keyB, opt: &opt}
+		w := bufio.NewWriter(p.Stdout)
+		term := opt.Lines.Delim
+		if len(term) == 0 {
+			term = []byte{'\n'}
+		}
+		err = join(w, a, b, opt.outputDelim(), term)
+		if err != nil {
+			return err
+		}
+		return w.Flush()
+	})
+}
+
+func join(w io.Writer, a, b *joinReader, odelim, term []byte) error {
+	ga, err := a.group()
+	if err != nil {
+		return err
+	}
+	gb, err := b.group()
+	if err != nil {
+		return err
+	}
+	var out [][]byte
+	for len(ga) > 0 && len(gb) > 0 {
+		switch c := bytes.Compare(ga[0].key, gb[0].key); {
+		case c < 0:
+			ga, err = a.group()
+		case c > 0:
+			gb, err = b.group()
+		default:
+			for _, la := range ga {
+				for _, lb := range gb {
+					out = append(out[:0], la.key)
+					out = append(out, la.rest...)
+					out = append(out, lb.rest...)
+					_, err = w.Write(append(bytes.Join(out, odelim), term...))
+					if err != nil {
+						return err
+					}
+				}
+			}
+			ga, err = a.group()
+			if err == nil {
+				gb, err = b.group()
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type joinLine struct {
+	key  []byte
+	rest [][]byte
+}
+
+// joinReader reads groups of consecutive lines with equal keys.
+type joinReader struct {
+	r    *lineReader
+	key  int
+	opt  *FieldOptions
+	next *joinLine
+	eof  bool
+}
+
+func (j *joinReader) read() (*joinLine, error) {
+	if j.next != nil {
+		line := j.next
+		j.next = nil
+		return line, nil
+	}
+	if j.eof {
+		return nil, nil
+	}
+	line, _, err := j.r.next()
+	if err == io.EOF {
+		j.eof = true
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	fields := j.opt.split(append([]byte(nil), line...))
+	jl := &joinLine{}
+	for i, field := range fields {
+		if i == j.key-1 {
+			jl.key = field
+		} else {
+			jl.rest = append(jl.rest, field)
+		}
+	}
+	return jl, nil
+}
+
+// group returns the next group of lines with equal keys, or an empty group at
+// the end of input.
+func (j *joinReader) group() ([]*joinLine, error) {
+	first, err := j.read()
+	if first == nil || err != nil {
+		return nil, err
+	}
+	group := []*joinLine{first}
+	for {
+		line, err := j.read()
+		if err != nil {
+			return nil, err
+		}
+		if line == nil {
+			return group, nil
+		}
+		if !bytes.Equal(line.key, first.key) {
+			j.next = line
+			return group, nil
+		}
+		group = append(group, line)
+	}
+}
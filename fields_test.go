@@ -0,0 +1,92 @@
+package nx
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/pipe.v2"
+)
+
+func TestParseFieldRanges(t *testing.T) {
+	ranges, err := ParseFieldRanges("1,3-5,7-,-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []FieldRange{{1, 1}, {3, 5}, {7, -1}, {1, 2}}
+	if !reflect.DeepEqual(ranges, expect) {
+		t.Errorf("%v (expected %v)", ranges, expect)
+	}
+	for _, s := range []string{"", "0", "a", "3-1"} {
+		_, err := ParseFieldRanges(s)
+		if err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
+
+func TestColumns(t *testing.T) {
+	for i, test := range []struct {
+		p       pipe.Pipe
+		in, out string
+	}{
+		{Cut("\t", Field(2)), "a\tb\tc\nd\te\n", "b\ne\n"},
+		{Cut(",", FieldRange{2, -1}), "a,b,c\nd\n", "b,c\n\n"},
+		{Cut(",", Field(3), Field(1)), "a,b,c\n", "c,a\n"},
+		{Fields(2, -1), "  a   b  c\nd e\n", "b c\ne e\n"},
+		{Fields(1), "\n", "\n"},
+		{Columns(FieldOptions{Delim: ",", Quote: '"', OutputDelim: "\t"}, Field(2), Field(1)), `a,"b,""c""",d` + "\n", `"b,""c"""` + "\ta\n"},
+		{Columns(FieldOptions{Quote: '\''}, Field(2)), "a 'b c' d\n", "'b c'\n"},
+	} {
+		out, err := runPipe(test.p, test.in)
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+		}
+		if out != test.out {
+			t.Errorf("test %d: %q (expected %q)", i, out, test.out)
+		}
+	}
+}
+
+func TestJoin(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nx-join-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	other := "a x1\nb x2\nb x3\nd x4\n"
+	err = ioutil.WriteFile(filepath.Join(dir, "other"), []byte(other), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	join := func(p pipe.Pipe, in string) string {
+		out := &pipe.OutputBuffer{}
+		s := newState(strings.NewReader(in), out)
+		s.Dir = dir
+		err := runTimeout(p, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(out.Bytes())
+	}
+
+	out := join(Join(2, 1, "other"), "1 a\n2 b\n3 b\n4 c\n5 d")
+	expect := "a 1 x1\nb 2 x2\nb 2 x3\nb 3 x2\nb 3 x3\nd 5 x4\n"
+	if out != expect {
+		t.Errorf("%q (expected %q)", out, expect)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, "other.csv"), []byte("a,x\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt := FieldOptions{Delim: ","}
+	out = join(Join(1, 1, "other.csv", opt), "a,1,2\n")
+	if out != "a,1,2,x\n" {
+		t.Errorf("%q", out)
+	}
+}
@@ -0,0 +1,248 @@
+package nx
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/pipe.v2"
+)
+
+// FindType restricts the types of files emitted by Find.
+type FindType int
+
+// Available FindType values.
+const (
+	FindAny FindType = iota
+	FindFile
+	FindDir
+	FindSymlink
+)
+
+// SymlinkPolicy determines how Find treats symbolic links.
+type SymlinkPolicy int
+
+// Available SymlinkPolicy values.
+const (
+	// SymlinkNoFollow examines links themselves and never descends into
+	// them, like `find -P`.
+	SymlinkNoFollow SymlinkPolicy = iota
+	// SymlinkFollowRoot follows a link given as the root but no others,
+	// like `find -H`.
+	SymlinkFollowRoot
+	// SymlinkFollow examines the targets of links and descends into linked
+	// directories, like `find -L`.  Links that would cause a cycle are
+	// reported and not descended into.
+	SymlinkFollow
+)
+
+// FindOptions configures Find.  The zero value emits every path beneath the
+// root, including the root itself.
+type FindOptions struct {
+	// Name holds shell patterns, in the syntax of filepath.Match, matched
+	// against the base name of each path.  If Name is not empty paths must
+	// match at least one pattern.
+	Name []string
+
+	// Regexp, if not nil, must match the path as emitted.
+	Regexp *regexp.Regexp
+
+	// Type restricts the types of files emitted.
+	Type FindType
+
+	// MinSize is the minimum size of files emitted in bytes.
+	MinSize int64
+
+	// MaxSize, if positive, is the maximum size of files emitted in bytes.
+	MaxSize int64
+
+	// ModifiedAfter and ModifiedBefore, if not zero, restrict the
+	// modification times of files emitted.
+	ModifiedAfter  time.Time
+	ModifiedBefore time.Time
+
+	// MinDepth is the minimum depth of paths emitted.  The root has depth 0.
+	MinDepth int
+
+	// MaxDepth, if positive, is the maximum depth descended to.
+	MaxDepth int
+
+	// Symlinks determines how symbolic links are treated.
+	Symlinks SymlinkPolicy
+
+	// Null terminates emitted paths with NUL bytes instead of newlines, like
+	// `find -print0`, for use with VarargsNUL.
+	Null bool
+}
+
+// Find returns a pipe.Pipe that emits the paths of files beneath root, which
+// is relative to the pipe.State Dir, that satisfy opts, like find(1).  Paths
+// begin with root and are emitted in lexical order, with each directory
+// preceding its contents.  An empty root is equivalent to ".".  Errors
+// reading files beneath root are written to stderr and the walk continues;
+// the first such error is returned after the walk completes.  A nil opts is
+// equivalent to a zero FindOptions.
+func Find(root string, opts *FindOptions) pipe.Pipe {
+	if opts == nil {
+		opts = &FindOptions{}
+	}
+	opt := *opts
+	if root == "" {
+		root = "."
+	}
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		for _, pattern := range opt.Name {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("nx: invalid name pattern %q: %v", pattern, err)
+			}
+		}
+		info, err := os.Lstat(p.Path(root))
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 && opt.Symlinks != SymlinkNoFollow {
+			if target, err := os.Stat(p.Path(root)); err == nil {
+				info = target
+			}
+		}
+		f := &finder{p: p, opt: &opt, w: bufio.NewWriter(p.Stdout)}
+		f.term = '\n'
+		if opt.Null {
+			f.term = 0
+		}
+		err = f.walk(root, info, 0, nil)
+		if err != nil {
+			return err
+		}
+		err = f.w.Flush()
+		if err != nil {
+			return err
+		}
+		return f.err
+	})
+}
+
+// finder walks a tree for Find.
+type finder struct {
+	p    *pipe.State
+	opt  *FindOptions
+	w    *bufio.Writer
+	term byte
+	err  error // the first error reading the tree
+}
+
+// report writes an error encountered walking the tree to stderr.
+func (f *finder) report(err error) {
+	if f.err == nil {
+		f.err = err
+	}
+	if f.p.Stderr != nil {
+		fmt.Fprintf(f.p.Stderr, "find: %v\n", err)
+	}
+}
+
+// walk visits path, described by info, and its descendants.  Ancestors holds
+// the directories above path when symlinks are followed.
+func (f *finder) walk(path string, info os.FileInfo, depth int, ancestors []os.FileInfo) error {
+	if depth >= f.opt.MinDepth && f.match(path, info) {
+		f.w.WriteString(path)
+		err := f.w.WriteByte(f.term)
+		if err != nil {
+			return err
+		}
+	}
+	if !info.IsDir() || f.opt.MaxDepth > 0 && depth >= f.opt.MaxDepth {
+		return nil
+	}
+	if f.opt.Symlinks == SymlinkFollow {
+		for _, a := range ancestors {
+			if os.SameFile(a, info) {
+				f.report(fmt.Errorf("file system loop detected at %s", path))
+				return nil
+			}
+		}
+		ancestors = append(ancestors, info)
+	}
+
+	dir, err := os.Open(f.p.Path(path))
+	if err != nil {
+		f.report(err)
+		return nil
+	}
+	names, err := dir.Readdirnames(-1)
+	dir.Close()
+	if err != nil {
+		f.report(err)
+		return nil
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		child := strings.TrimSuffix(path, string(filepath.Separator)) + string(filepath.Separator) + name
+		info, err := os.Lstat(f.p.Path(child))
+		if err != nil {
+			f.report(err)
+			continue
+		}
+		if info.Mode()&os.ModeSymlink != 0 && f.opt.Symlinks == SymlinkFollow {
+			if target, err := os.Stat(f.p.Path(child)); err == nil {
+				info = target
+			}
+		}
+		err = f.walk(child, info, depth+1, ancestors)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// match returns true if path satisfies the predicates of f.opt.
+func (f *finder) match(path string, info os.FileInfo) bool {
+	opt := f.opt
+	switch mode := info.Mode(); opt.Type {
+	case FindFile:
+		if !mode.IsRegular() {
+			return false
+		}
+	case FindDir:
+		if !mode.IsDir() {
+			return false
+		}
+	case FindSymlink:
+		if mode&os.ModeSymlink == 0 {
+			return false
+		}
+	}
+	if info.Size() < opt.MinSize || opt.MaxSize > 0 && info.Size() > opt.MaxSize {
+		return false
+	}
+	mtime := info.ModTime()
+	if !opt.ModifiedAfter.IsZero() && !mtime.After(opt.ModifiedAfter) {
+		return false
+	}
+	if !opt.ModifiedBefore.IsZero() && !mtime.Before(opt.ModifiedBefore) {
+		return false
+	}
+	if len(opt.Name) > 0 {
+		base := filepath.Base(path)
+		matched := false
+		for _, pattern := range opt.Name {
+			if ok, _ := filepath.Match(pattern, base); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if opt.Regexp != nil && !opt.Regexp.MatchString(path) {
+		return false
+	}
+	return true
+}
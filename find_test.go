@@ -0,0 +1,106 @@
+package nx
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/pipe.v2"
+)
+
+func TestFind(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nx-find-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"a/b", "a/c/d"} {
+		err = os.MkdirAll(filepath.Join(dir, name), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		"x.txt":       "hello",
+		"a/y.go":      "package y\n",
+		"a/b/z.txt":   "",
+		"a/c/d/w.txt": strings.Repeat("w", 100),
+	}
+	for name, content := range files {
+		err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(dir, "x.txt"), old, old)
+	err = os.Symlink("c", filepath.Join(dir, "a/link"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Symlink("..", filepath.Join(dir, "a/c/up"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	find := func(p pipe.Pipe, stdout, stderr io.Writer) error {
+		s := newState(nil, stdout)
+		if stderr != nil {
+			s.Stderr = stderr
+		}
+		s.Dir = dir
+		return runTimeout(p, s)
+	}
+
+	for i, test := range []struct {
+		root string
+		opt  FindOptions
+		out  string
+	}{
+		{"", FindOptions{Type: FindFile}, "./a/b/z.txt ./a/c/d/w.txt ./a/y.go ./x.txt"},
+		{"a", FindOptions{MaxDepth: 1}, "a a/b a/c a/link a/y.go"},
+		{"a", FindOptions{MinDepth: 2, Type: FindDir}, "a/c/d"},
+		{".", FindOptions{Name: []string{"*.go", "z*"}}, "./a/b/z.txt ./a/y.go"},
+		{"a", FindOptions{Regexp: regexp.MustCompile(`/c/`)}, "a/c/d a/c/d/w.txt a/c/up"},
+		{"a", FindOptions{Type: FindSymlink}, "a/c/up a/link"},
+		{"a", FindOptions{Type: FindFile, Symlinks: SymlinkFollow, Name: []string{"w.txt"}}, "a/c/d/w.txt a/link/d/w.txt"},
+		{".", FindOptions{Type: FindFile, MinSize: 1, MaxSize: 10}, "./a/y.go ./x.txt"},
+		{".", FindOptions{Type: FindFile, ModifiedBefore: time.Now().Add(-time.Minute)}, "./x.txt"},
+		{".", FindOptions{Type: FindFile, ModifiedAfter: time.Now().Add(-time.Minute), Name: []string{"*.txt"}}, "./a/b/z.txt ./a/c/d/w.txt"},
+	} {
+		var out, stderr bytes.Buffer
+		err := find(Find(test.root, &test.opt), &out, &stderr)
+		if err != nil && test.opt.Symlinks != SymlinkFollow {
+			t.Errorf("test %d: %v", i, err)
+		}
+		if got := strings.Join(strings.Fields(out.String()), " "); got != test.out {
+			t.Errorf("test %d: output %q (expected %q)", i, got, test.out)
+		}
+	}
+
+	var out bytes.Buffer
+	err = find(Find("a/b", &FindOptions{Null: true}), &out, nil)
+	if err != nil || out.String() != "a/b\x00a/b/z.txt\x00" {
+		t.Errorf("output %q, error %v", out.String(), err)
+	}
+
+	var stderr bytes.Buffer
+	err = find(Find("a", &FindOptions{Symlinks: SymlinkFollow}), nil, &stderr)
+	if err == nil || !strings.Contains(stderr.String(), "loop") {
+		t.Errorf("loop not reported: %v %q", err, stderr.String())
+	}
+	if err := find(Find("missing", nil), nil, nil); err == nil {
+		t.Errorf("missing root accepted")
+	}
+
+	out.Reset()
+	err = find(pipe.Line(Find("a/b", nil), Tac(nil)), &out, nil)
+	if err != nil || out.String() != "a/b/z.txt\na/b\n" {
+		t.Errorf("line: output %q, error %v", out.String(), err)
+	}
+}
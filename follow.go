@@ -0,0 +1,250 @@
+package nx
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"gopkg.in/pipe.v2"
+)
+
+// FollowBackend determines how Follow detects changes to a file.
+type FollowBackend int
+
+// Available FollowBackend values.
+const (
+	// FollowAuto uses FollowNotify where it is supported and FollowPoll
+	// elsewhere.
+	FollowAuto FollowBackend = iota
+	// FollowPoll checks the file for changes periodically.
+	FollowPoll
+	// FollowNotify uses filesystem change notifications (inotify).  It is
+	// only supported on Linux.
+	FollowNotify
+)
+
+// ErrNotifyUnsupported is returned by Follow when FollowNotify is requested on
+// a platform without filesystem change notifications.
+var ErrNotifyUnsupported = errors.New("nx: file change notification is not supported")
+
+// FollowOptions configures Follow.  The zero value is ready to use.
+type FollowOptions struct {
+	Backend FollowBackend
+
+	// Interval is the time between checks for changes when polling.  When
+	// notifications are used the file is still checked at Interval in case
+	// a notification was missed.  If Interval is zero one second is used.
+	Interval time.Duration
+}
+
+// Follow returns a pipe.Pipe that emits the last n lines of the file at path
+// and then emits data appended to the file as it is written, like `tail -F`.
+// If the file is truncated it is read again from the beginning.  If the file
+// is replaced, as happens during log rotation, the remainder of the old file
+// is emitted and the new file is read from its beginning.  Follow only returns
+// when the pipe is killed or an error is encountered.  Stdin is ignored.  A
+// relative path is interpreted relative to the pipe.State Dir.  A nil opts is
+// equivalent to a zero FollowOptions.
+func Follow(path string, n int, opts *FollowOptions) pipe.Pipe {
+	if opts == nil {
+		opts = &FollowOptions{}
+	}
+	return func(p *pipe.State) error {
+		t := &followTask{
+			path: p.Path(path),
+			n:    n,
+			opts: *opts,
+			done: make(chan struct{}),
+		}
+		return p.AddTask(t)
+	}
+}
+
+type followTask struct {
+	path string
+	n    int
+	opts FollowOptions
+	done chan struct{}
+	kill sync.Once
+}
+
+func (t *followTask) Kill() {
+	t.kill.Do(func() { close(t.done) })
+}
+
+func (t *followTask) killed() bool {
+	select {
+	case <-t.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (t *followTask) Run(p *pipe.State) error {
+	interval := t.opts.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	f, err := os.Open(t.path)
+	if err != nil {
+		return err
+	}
+	defer func() { f.Close() }()
+
+	lines, err := lastLines(newLineReader(f, LineOptions{}), t.n)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(p.Stdout)
+	for _, ln := range lines {
+		_, err := w.Write(ln)
+		if err != nil {
+			return err
+		}
+	}
+	err = w.Flush()
+	if err != nil {
+		return err
+	}
+
+	watch, err := newFollowWatcher(t.path, t.opts.Backend)
+	if err != nil {
+		return err
+	}
+	defer watch.Close()
+
+	buf := make([]byte, 32<<10)
+	for {
+		_, err := io.CopyBuffer(p.Stdout, onlyReader{f}, buf)
+		if err != nil {
+			return err
+		}
+		if t.killed() {
+			return nil
+		}
+		err = watch.wait(t.done, interval)
+		if err != nil {
+			return err
+		}
+		if t.killed() {
+			return nil
+		}
+
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		off, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		if info.Size() < off {
+			// truncated
+			_, err = f.Seek(0, io.SeekStart)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		pathInfo, err := os.Stat(t.path)
+		if os.IsNotExist(err) {
+			// the replacement file has not been created yet.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !os.SameFile(info, pathInfo) {
+			_, err := io.CopyBuffer(p.Stdout, onlyReader{f}, buf)
+			if err != nil {
+				return err
+			}
+			rotated, err := os.Open(t.path)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			f.Close()
+			f = rotated
+		}
+	}
+}
+
+// onlyReader hides the WriterTo implementation of *os.File from io.Copy.
+type onlyReader struct {
+	io.Reader
+}
+
+// followWatcher blocks until a followed file may have changed.
+type followWatcher interface {
+	// wait returns after the file may have changed, d has elapsed, or done is
+	// closed.
+	wait(done <-chan struct{}, d time.Duration) error
+	Close() error
+}
+
+func newFollowWatcher(path string, backend FollowBackend) (followWatcher, error) {
+	switch backend {
+	case FollowPoll:
+		return pollWatcher{}, nil
+	case FollowNotify:
+		return newNotifyWatcher(path)
+	}
+	w, err := newNotifyWatcher(path)
+	if err != nil {
+		return pollWatcher{}, nil
+	}
+	return w, nil
+}
+
+type pollWatcher struct{}
+
+func (pollWatcher) wait(done <-chan struct{}, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-done:
+	}
+	return nil
+}
+
+func (pollWatcher) Close() error {
+	return nil
+}
+
+// lastLines returns the last n lines read from r before EOF, including their
+// terminators.
+func lastLines(r *lineReader, n int) ([][]byte, error) {
+	if n <= 0 {
+		_, err := io.Copy(ioutil.Discard, r.r)
+		return nil, err
+	}
+	buf := make([][]byte, n)
+	var i, count int
+	for {
+		line, term, err := r.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		buf[i] = append(append(buf[i][:0], line...), term...)
+		i = (i + 1) % n
+		count++
+	}
+	if count < n {
+		return buf[:count], nil
+	}
+	return append(buf[i:], buf[:i]...), nil
+}
@@ -0,0 +1,64 @@
+package nx
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// notifyWatcher uses inotify to watch the directory containing a file.
+// Watching the directory allows rotation to be detected as well as writes.
+type notifyWatcher struct {
+	f   *os.File
+	buf []byte
+}
+
+func newNotifyWatcher(path string) (followWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	const mask = syscall.IN_MODIFY | syscall.IN_ATTRIB | syscall.IN_CREATE |
+		syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO |
+		syscall.IN_CLOSE_WRITE
+	_, err = syscall.InotifyAddWatch(fd, filepath.Dir(path), mask)
+	if err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("inotify_add_watch", err)
+	}
+	w := &notifyWatcher{
+		f:   os.NewFile(uintptr(fd), "inotify"),
+		buf: make([]byte, 4096),
+	}
+	return w, nil
+}
+
+func (w *notifyWatcher) wait(done <-chan struct{}, d time.Duration) error {
+	err := w.f.SetReadDeadline(time.Now().Add(d))
+	if err != nil {
+		return err
+	}
+	// expiring the deadline early interrupts the read when done is closed.
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-done:
+			w.f.SetReadDeadline(time.Now())
+		case <-stop:
+		}
+	}()
+	// the content of events is irrelevant, the followed file is always
+	// checked after wait returns.
+	_, err = w.f.Read(w.buf)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return nil
+	}
+	return err
+}
+
+func (w *notifyWatcher) Close() error {
+	return w.f.Close()
+}
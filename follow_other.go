@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package nx
+
+func newNotifyWatcher(path string) (followWatcher, error) {
+	return nil, ErrNotifyUnsupported
+}
@@ -0,0 +1,149 @@
+package nx
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/pipe.v2"
+)
+
+// syncBuffer is a bytes.Buffer that is safe for concurrent use.
+type syncBuffer struct {
+	mut sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	return b.buf.String()
+}
+
+func waitFor(t *testing.T, b *syncBuffer, s string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.HasSuffix(b.String(), s) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %q: %q", s, b.String())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestFollow(t *testing.T) {
+	for _, backend := range []FollowBackend{FollowPoll, FollowAuto} {
+		testFollow(t, backend)
+	}
+}
+
+func testFollow(t *testing.T, backend FollowBackend) {
+	dir, err := ioutil.TempDir("", "nx-follow-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "log")
+	err = ioutil.WriteFile(path, []byte("a\nb\nc\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := new(syncBuffer)
+	task := &followTask{
+		path: path,
+		n:    2,
+		opts: FollowOptions{Backend: backend, Interval: 10 * time.Millisecond},
+		done: make(chan struct{}),
+	}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- task.Run(&pipe.State{Stdout: out})
+	}()
+	waitFor(t, out, "b\nc\n")
+
+	appendFile := func(path, s string) {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		_, err = f.WriteString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	appendFile(path, "d\n")
+	waitFor(t, out, "b\nc\nd\n")
+
+	err = os.Truncate(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	appendFile(path, "e\n")
+	waitFor(t, out, "d\ne\n")
+
+	err = os.Rename(path, path+".1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendFile(path+".1", "f\n")
+	appendFile(path, "g\n")
+	waitFor(t, out, "e\nf\ng\n")
+
+	task.Kill()
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("follow was not killed")
+	}
+}
+
+func TestFollow_kill(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nx-follow-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "log")
+	err = ioutil.WriteFile(path, []byte("a\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, backend := range []FollowBackend{FollowPoll, FollowAuto} {
+		out := new(syncBuffer)
+		s := pipe.NewState(out, nil)
+		err := Follow(path, 1, &FollowOptions{Backend: backend, Interval: time.Hour})(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		errc := make(chan error, 1)
+		go func() { errc <- s.RunTasks() }()
+		waitFor(t, out, "a\n")
+		start := time.Now()
+		s.Kill()
+		select {
+		case <-errc:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("backend %d: follow was not killed", backend)
+		}
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("backend %d: kill took %v", backend, d)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package nx
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+
+	"gopkg.in/pipe.v2"
+)
+
+// ErrNoMatch is returned by Grep in Quiet mode when no lines are selected.
+var ErrNoMatch = errors.New("nx: no lines matched")
+
+// GrepOptions configures Grep.  The zero value emits lines matching the
+// pattern read from stdin.
+type GrepOptions struct {
+	// Invert selects lines that do not match, like `grep -v`.
+	Invert bool
+
+	// LineNumbers prefixes output lines with their line numbers, like
+	// `grep -n`.
+	LineNumbers bool
+
+	// Count emits the number of selected lines instead of the lines, like
+	// `grep -c`.
+	Count bool
+
+	// Before and After are the numbers of lines of context emitted before and
+	// after selected lines, like `grep -B` and `grep -A`.  Set both for the
+	// behavior of `grep -C`.  Non-adjacent groups of lines are separated by
+	// "--" lines.
+	Before int
+	After  int
+
+	// MaxCount, if positive, stops reading an input after MaxCount lines are
+	// selected, like `grep -m`.  Trailing context is still emitted.
+	MaxCount int
+
+	// OnlyMatching emits each non-empty match on a line of its own instead
+	// of selected lines, like `grep -o`.  Context is not emitted.
+	OnlyMatching bool
+
+	// Files, if not empty, are read instead of stdin.  File names are
+	// relative to the pipe.State Dir.  When more than one file is given
+	// output lines are prefixed by the name of the file they came from.
+	Files []string
+
+	// FilesWithMatches emits the name of each input containing a selected
+	// line instead of the lines, like `grep -l`.  Stdin is named
+	// "(standard input)".
+	FilesWithMatches bool
+
+	// Quiet emits nothing and stops at the first selected line, like
+	// `grep -q`.  ErrNoMatch is returned if no line is selected.
+	Quiet bool
+
+	// Lines determines how input is split into lines.
+	Lines LineOptions
+}
+
+// Grep returns a pipe.Pipe that emits the lines read from stdin, or the files
+// given in opts, that match re, like grep(1).  A nil opts is equivalent to a
+// zero GrepOptions.
+func Grep(re *regexp.Regexp, opts *GrepOptions) pipe.Pipe {
+	if opts == nil {
+		opts = &GrepOptions{}
+	}
+	opt := *opts
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		g := &grepper{re: re, opt: &opt, w: bufio.NewWriter(p.Stdout)}
+		g.delim = opt.Lines.Delim
+		if len(g.delim) == 0 {
+			g.delim = []byte{'\n'}
+		}
+		var total int
+		if len(opt.Files) == 0 {
+			n, err := g.grep("(standard input)", p.Stdin)
+			if err != nil {
+				return err
+			}
+			total += n
+		}
+		g.prefix = len(opt.Files) > 1
+		for _, name := range opt.Files {
+			if opt.Quiet && total > 0 {
+				break
+			}
+			f, err := os.Open(p.Path(name))
+			if err != nil {
+				return err
+			}
+			n, err := g.grep(name, f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+			total += n
+		}
+		if opt.Quiet && total == 0 {
+			return ErrNoMatch
+		}
+		return g.w.Flush()
+	})
+}
+
+// grepper searches inputs for Grep.
+type grepper struct {
+	re      *regexp.Regexp
+	opt     *GrepOptions
+	w       *bufio.Writer
+	delim   []byte
+	prefix  bool // prefix output lines with file names
+	printed bool // a line has been emitted, so groups need separators
+}
+
+type grepLine struct {
+	n    int
+	line []byte
+	term []byte
+}
+
+// grep searches r, named name, and returns the number of lines selected.
+func (g *grepper) grep(name string, r io.Reader) (int, error) {
+	opt := g.opt
+	lines := !opt.Count && !opt.FilesWithMatches && !opt.Quiet
+	context := lines && !opt.OnlyMatching && (opt.Before > 0 || opt.After > 0)
+	lr := newLineReader(r, opt.Lines)
+	var before []grepLine
+	var matches, after, last int
+	for n := 1; ; n++ {
+		line, term, err := lr.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return matches, err
+		}
+		if opt.MaxCount > 0 && matches >= opt.MaxCount {
+			if !context || after == 0 {
+				break
+			}
+			after--
+			last = n
+			err = g.emit(name, n, '-', line, term)
+			if err != nil {
+				return matches, err
+			}
+			continue
+		}
+		if g.re.Match(line) == opt.Invert {
+			switch {
+			case !context:
+			case after > 0:
+				after--
+				last = n
+				err = g.emit(name, n, '-', line, term)
+			case opt.Before > 0:
+				if len(before) == opt.Before {
+					before = append(before[:0], before[1:]...)
+				}
+				before = append(before, grepLine{n, append([]byte(nil), line...), append([]byte(nil), term...)})
+			}
+			if err != nil {
+				return matches, err
+			}
+			continue
+		}
+		matches++
+		switch {
+		case opt.Quiet || opt.FilesWithMatches:
+			return matches, g.list(name)
+		case opt.Count:
+			continue
+		case opt.OnlyMatching:
+			if opt.Invert {
+				continue
+			}
+			for _, loc := range g.re.FindAllIndex(line, -1) {
+				if loc[1] > loc[0] {
+					err = g.emit(name, n, ':', line[loc[0]:loc[1]], nil)
+					if err != nil {
+						return matches, err
+					}
+				}
+			}
+			continue
+		}
+		if context {
+			first := n - len(before)
+			if g.printed && (last == 0 || last < first-1) {
+				g.w.WriteString("--")
+				g.w.Write(g.delim)
+			}
+			for _, b := range before {
+				g.emit(name, b.n, '-', b.line, b.term)
+			}
+			before = before[:0]
+			after = opt.After
+		}
+		last = n
+		err = g.emit(name, n, ':', line, term)
+		if err != nil {
+			return matches, err
+		}
+	}
+	if opt.Count {
+		if g.prefix {
+			g.w.WriteString(name)
+			g.w.WriteByte(':')
+		}
+		g.w.WriteString(strconv.Itoa(matches))
+		_, err := g.w.Write(g.delim)
+		return matches, err
+	}
+	return matches, nil
+}
+
+// list emits name for FilesWithMatches.
+func (g *grepper) list(name string) error {
+	if !g.opt.FilesWithMatches || g.opt.Quiet {
+		return nil
+	}
+	g.w.WriteString(name)
+	_, err := g.w.Write(g.delim)
+	return err
+}
+
+// emit writes an output line.  Sep is ':' for selected lines and '-' for
+// context lines.
+func (g *grepper) emit(name string, n int, sep byte, line, term []byte) error {
+	g.printed = true
+	if g.prefix {
+		g.w.WriteString(name)
+		g.w.WriteByte(sep)
+	}
+	if g.opt.LineNumbers {
+		g.w.WriteString(strconv.Itoa(n))
+		g.w.WriteByte(sep)
+	}
+	g.w.Write(line)
+	if len(term) == 0 {
+		term = g.delim
+	}
+	_, err := g.w.Write(term)
+	return err
+}
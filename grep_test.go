@@ -0,0 +1,103 @@
+package nx
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"gopkg.in/pipe.v2"
+)
+
+func TestGrep(t *testing.T) {
+	in := "a1\nb\nc\na2\nd\ne\nf\ng\na3\nh"
+	a := regexp.MustCompile(`a`)
+	for i, test := range []struct {
+		re  *regexp.Regexp
+		opt GrepOptions
+		in  string
+		out string
+	}{
+		{a, GrepOptions{}, in, "a1\na2\na3\n"},
+		{a, GrepOptions{Invert: true, MaxCount: 3}, in, "b\nc\nd\n"},
+		{a, GrepOptions{LineNumbers: true}, in, "1:a1\n4:a2\n9:a3\n"},
+		{a, GrepOptions{Count: true}, in, "3\n"},
+		{a, GrepOptions{Count: true, MaxCount: 2}, in, "2\n"},
+		{a, GrepOptions{After: 1}, in, "a1\nb\n--\na2\nd\n--\na3\nh\n"},
+		{a, GrepOptions{Before: 2, LineNumbers: true}, in, "1:a1\n2-b\n3-c\n4:a2\n--\n7-f\n8-g\n9:a3\n"},
+		{a, GrepOptions{Before: 1, After: 1}, "x\na\ny\nz\na\n", "x\na\ny\nz\na\n"},
+		{a, GrepOptions{Before: 1, After: 1}, "x\na\ny\nz\nw\na\n", "x\na\ny\n--\nw\na\n"},
+		{a, GrepOptions{After: 2, MaxCount: 1}, in, "a1\nb\nc\n"},
+		{regexp.MustCompile(`[0-9]+`), GrepOptions{OnlyMatching: true, LineNumbers: true}, "a12b3\nc\n4", "1:12\n1:3\n3:4\n"},
+		{a, GrepOptions{FilesWithMatches: true}, in, "(standard input)\n"},
+		{a, GrepOptions{FilesWithMatches: true}, "x\n", ""},
+		{a, GrepOptions{Lines: NULLines}, "a\x00b\x00xa", "a\x00xa\x00"},
+	} {
+		out, err := runPipe(Grep(test.re, &test.opt), test.in)
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+		}
+		if out != test.out {
+			t.Errorf("test %d: output %q (expected %q)", i, out, test.out)
+		}
+	}
+
+	out, err := runPipe(Grep(a, &GrepOptions{Quiet: true}), in)
+	if err != nil || out != "" {
+		t.Errorf("output %q, error %v", out, err)
+	}
+	_, err = runPipe(Grep(regexp.MustCompile(`z`), &GrepOptions{Quiet: true}), in)
+	if err != ErrNoMatch {
+		t.Errorf("error %v (expected %v)", err, ErrNoMatch)
+	}
+
+	out, err = runPipe(pipe.Line(Grep(a, nil), Grep(regexp.MustCompile(`[12]`), &GrepOptions{Invert: true})), in)
+	if err != nil || out != "a3\n" {
+		t.Errorf("line: output %q, error %v", out, err)
+	}
+}
+
+func TestGrepFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nx-grep-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, content := range map[string]string{"one": "a\nb\n", "two": "b\nc\n", "three": "a\n"} {
+		err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := []string{"one", "two", "three"}
+	a := regexp.MustCompile(`a`)
+	for i, test := range []struct {
+		opt GrepOptions
+		out string
+	}{
+		{GrepOptions{}, "one:a\nthree:a\n"},
+		{GrepOptions{FilesWithMatches: true}, "one\nthree\n"},
+		{GrepOptions{Count: true}, "one:1\ntwo:0\nthree:1\n"},
+		{GrepOptions{LineNumbers: true, After: 1}, "one:1:a\none-2-b\n--\nthree:1:a\n"},
+	} {
+		test.opt.Files = files
+		var out bytes.Buffer
+		s := newState(nil, &out)
+		s.Dir = dir
+		err := runTimeout(Grep(a, &test.opt), s)
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+		}
+		if out.String() != test.out {
+			t.Errorf("test %d: output %q (expected %q)", i, out.String(), test.out)
+		}
+	}
+	s := newState(nil, nil)
+	s.Dir = dir
+	err = runTimeout(Grep(a, &GrepOptions{Files: []string{"missing"}}), s)
+	if err == nil {
+		t.Errorf("missing file accepted")
+	}
+}
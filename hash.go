@@ -0,0 +1,130 @@
+package nx
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"gopkg.in/pipe.v2"
+)
+
+// HashAlgo names a hash algorithm used by Hash, HashTee and Verify.
+type HashAlgo string
+
+// Available HashAlgo values.
+const (
+	MD5    HashAlgo = "md5"
+	SHA1   HashAlgo = "sha1"
+	SHA256 HashAlgo = "sha256"
+	// CRC32 is the IEEE CRC-32 checksum, written in big-endian order.
+	CRC32 HashAlgo = "crc32"
+	// XXHash is the 64-bit xxHash algorithm (XXH64) with a seed of zero,
+	// written in big-endian order like xxhsum.  It is not cryptographically
+	// secure.
+	XXHash HashAlgo = "xxhash"
+)
+
+func (algo HashAlgo) new() (hash.Hash, error) {
+	switch algo {
+	case MD5:
+		return md5.New(), nil
+	case SHA1:
+		return sha1.New(), nil
+	case SHA256:
+		return sha256.New(), nil
+	case CRC32:
+		return crc32.NewIEEE(), nil
+	case XXHash:
+		return newXXHash64(), nil
+	}
+	return nil, fmt.Errorf("nx: unknown hash algorithm %q", string(algo))
+}
+
+// ChecksumError is returned by Verify when the digest of its input does not
+// match the expected value.
+type ChecksumError struct {
+	Algo     HashAlgo
+	Expected string
+	Actual   string
+}
+
+func (err *ChecksumError) Error() string {
+	return fmt.Sprintf("%s checksum mismatch: expected %s, got %s", err.Algo, err.Expected, err.Actual)
+}
+
+// Hash returns a pipe.Pipe that emits the hexadecimal digest of the input
+// read from stdin to stdout, in the format of sha256sum(1) reading stdin.
+func Hash(algo HashAlgo) pipe.Pipe {
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		sum, err := hashCopy(algo, ioutil.Discard, p.Stdin)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(p.Stdout, "%x  -\n", sum)
+		return err
+	})
+}
+
+// HashTee returns a pipe.Pipe that copies stdin to stdout unchanged and
+// stores the digest of the data in *sum once input is exhausted.
+func HashTee(algo HashAlgo, sum *[]byte) pipe.Pipe {
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		h, err := hashCopy(algo, p.Stdout, p.Stdin)
+		if err != nil {
+			return err
+		}
+		*sum = h
+		return nil
+	})
+}
+
+// Verify returns a pipe.Pipe that copies stdin to stdout unchanged and fails
+// with a *ChecksumError if the digest of the data does not equal expected,
+// given in hexadecimal.  Expected may be a line in the format of sha256sum(1),
+// in which case only the digest is compared.  Because data is forwarded as it
+// is read, stages downstream receive all of it before the mismatch is
+// reported.
+func Verify(algo HashAlgo, expected string) pipe.Pipe {
+	if i := strings.IndexAny(expected, " \t"); i >= 0 {
+		expected = expected[:i]
+	}
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		want, err := hex.DecodeString(expected)
+		if err != nil {
+			return fmt.Errorf("nx: invalid checksum %q: %v", expected, err)
+		}
+		sum, err := hashCopy(algo, p.Stdout, p.Stdin)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(sum, want) {
+			return &ChecksumError{
+				Algo:     algo,
+				Expected: strings.ToLower(expected),
+				Actual:   hex.EncodeToString(sum),
+			}
+		}
+		return nil
+	})
+}
+
+// hashCopy copies r to w and returns the digest of the data copied.
+func hashCopy(algo HashAlgo, w io.Writer, r io.Reader) ([]byte, error) {
+	h, err := algo.new()
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.Copy(io.MultiWriter(w, h), r)
+	if err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
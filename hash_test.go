@@ -0,0 +1,89 @@
+package nx
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"gopkg.in/pipe.v2"
+)
+
+func TestHash(t *testing.T) {
+	for i, test := range []struct {
+		algo HashAlgo
+		in   string
+		sum  string
+	}{
+		{MD5, "abc", "900150983cd24fb0d6963f7d28e17f72"},
+		{SHA1, "abc", "a9993e364706816aba3e25717850c26c9cd0d89d"},
+		{SHA256, "abc", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{CRC32, "123456789", "cbf43926"},
+		{XXHash, "", "ef46db3751d8e999"},
+		{XXHash, "a", "d24ec4f1a98c6e5b"},
+		{XXHash, "abc", "44bc2cf5ad770999"},
+	} {
+		out, err := runPipe(Hash(test.algo), test.in)
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+		}
+		if out != test.sum+"  -\n" {
+			t.Errorf("test %d: output %q (expected %q)", i, out, test.sum+"  -\n")
+		}
+	}
+	if _, err := runPipe(Hash("bogus"), ""); err == nil {
+		t.Errorf("unknown algorithm accepted")
+	}
+}
+
+func TestXXHashStreaming(t *testing.T) {
+	in := []byte(strings.Repeat("0123456789abcdef", 20) + "xyz")
+	whole := newXXHash64()
+	whole.Write(in)
+	for _, chunk := range []int{1, 3, 7, 31, 32, 33, 100} {
+		h := newXXHash64()
+		for b := in; len(b) > 0; {
+			n := chunk
+			if n > len(b) {
+				n = len(b)
+			}
+			h.Write(b[:n])
+			b = b[n:]
+		}
+		if h.Sum64() != whole.Sum64() {
+			t.Errorf("chunk %d: %x (expected %x)", chunk, h.Sum64(), whole.Sum64())
+		}
+	}
+}
+
+func TestHashTeeVerify(t *testing.T) {
+	var sum []byte
+	out, err := runPipe(HashTee(SHA1, &sum), "abc")
+	if err != nil || out != "abc" {
+		t.Errorf("output %q, error %v", out, err)
+	}
+	if hex.EncodeToString(sum) != "a9993e364706816aba3e25717850c26c9cd0d89d" {
+		t.Errorf("sum %x", sum)
+	}
+
+	out, err = runPipe(Verify(MD5, "900150983CD24FB0D6963F7D28E17F72  file.txt"), "abc")
+	if err != nil || out != "abc" {
+		t.Errorf("output %q, error %v", out, err)
+	}
+	_, err = runPipe(Verify(MD5, "900150983cd24fb0d6963f7d28e17f72"), "abd")
+	cerr, ok := err.(*ChecksumError)
+	if !ok || cerr.Expected != "900150983cd24fb0d6963f7d28e17f72" {
+		t.Errorf("error %v", err)
+	}
+	if _, err = runPipe(Verify(MD5, "xyz"), "abc"); err == nil {
+		t.Errorf("invalid checksum accepted")
+	}
+	out, err = runPipe(pipe.Line(
+		pipe.Print("abc"),
+		HashTee(SHA1, &sum),
+		Verify(MD5, "900150983cd24fb0d6963f7d28e17f72"),
+		Hash(MD5),
+	), "")
+	if err != nil || out != "900150983cd24fb0d6963f7d28e17f72  -\n" {
+		t.Errorf("line: output %q, error %v", out, err)
+	}
+}
@@ -0,0 +1,246 @@
+package nx
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"gopkg.in/pipe.v2"
+)
+
+// LastFile returns a pipe.Pipe that emits the last n lines of the file at
+// path, relative to the pipe.State Dir, to stdout.  Only the end of the file is
+// read unless opts requires lines to be split in a way that can only be done
+// reading forward.  Stdin is ignored.
+func LastFile(path string, n int, opts ...LineOptions) pipe.Pipe {
+	opt := lineOptions(opts)
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		f, err := os.Open(p.Path(path))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if delim, ok := opt.seekDelim(); ok {
+			return copyLastLines(p.Stdout, f, n, delim)
+		}
+		return writeLastLines(p.Stdout, f, n, opt)
+	})
+}
+
+// LastBytes returns a pipe.Pipe that emits the last n bytes read from stdin
+// before encountering EOF to stdout.  If stdin is a regular file LastBytes
+// reads only the end of the file.
+func LastBytes(n int64) pipe.Pipe {
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		if f, ok := regularFile(p.Stdin); ok {
+			return copyFileRange(p.Stdout, f, func(start, size int64) (int64, int64, error) {
+				if size-start > n {
+					start = size - n
+				}
+				return start, size, nil
+			})
+		}
+		if n <= 0 {
+			_, err := io.Copy(ioutil.Discard, p.Stdin)
+			return err
+		}
+		ring := newByteRing(n, nil)
+		_, err := io.Copy(ring, p.Stdin)
+		if err != nil {
+			return err
+		}
+		_, err = ring.WriteTo(p.Stdout)
+		return err
+	})
+}
+
+// TrimLastBytes returns a pipe.Pipe that emits all but the last n bytes read
+// from stdin to stdout, like `head -c -n`.  At most n bytes are buffered.  If
+// stdin is a regular file TrimLastBytes does not buffer input.
+func TrimLastBytes(n int64) pipe.Pipe {
+	if n <= 0 {
+		return pipe.Tee(ioutil.Discard)
+	}
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		if f, ok := regularFile(p.Stdin); ok {
+			return copyFileRange(p.Stdout, f, func(start, size int64) (int64, int64, error) {
+				end := size - n
+				if end < start {
+					end = start
+				}
+				return start, end, nil
+			})
+		}
+		w := bufio.NewWriter(p.Stdout)
+		_, err := io.Copy(newByteRing(n, w), p.Stdin)
+		if err != nil {
+			return err
+		}
+		return w.Flush()
+	})
+}
+
+// regularFile returns r as an *os.File if it is a regular file, which can be
+// read at arbitrary offsets.
+func regularFile(r io.Reader) (*os.File, bool) {
+	f, ok := r.(*os.File)
+	if !ok {
+		return nil, false
+	}
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return nil, false
+	}
+	_, err = f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, false
+	}
+	return f, true
+}
+
+// copyFileRange writes the range of f selected by fn to w, given the current
+// offset of f and its size.  Afterwards f is positioned at its end, as if it
+// had been read entirely.
+func copyFileRange(w io.Writer, f *os.File, fn func(start, size int64) (from, to int64, err error)) error {
+	start, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+	if start > size {
+		start = size
+	}
+	from, to, err := fn(start, size)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, io.NewSectionReader(f, from, to-from))
+	if err != nil {
+		return err
+	}
+	_, err = f.Seek(size, io.SeekStart)
+	return err
+}
+
+// copyLastLines writes the last n lines of f terminated by delim, following
+// its current offset, to w.
+func copyLastLines(w io.Writer, f *os.File, n int, delim byte) error {
+	return copyFileRange(w, f, func(start, size int64) (int64, int64, error) {
+		off, err := lastLinesOffset(f, start, size, n, delim)
+		return off, size, err
+	})
+}
+
+// lastLinesOffset returns the offset of the beginning of the last n lines
+// terminated by delim between offsets start and size in r.  The file is read
+// backwards in blocks from size.  A delimiter at the end of the range
+// terminates the final line and does not begin an empty one.
+func lastLinesOffset(r io.ReaderAt, start, size int64, n int, delim byte) (int64, error) {
+	if n <= 0 {
+		return size, nil
+	}
+	buf := make([]byte, 64<<10)
+	var count int
+	pos := size
+	for pos > start {
+		blk := int64(len(buf))
+		if pos-start < blk {
+			blk = pos - start
+		}
+		pos -= blk
+		_, err := r.ReadAt(buf[:blk], pos)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		for i := blk - 1; i >= 0; i-- {
+			if buf[i] != delim || pos+i == size-1 {
+				continue
+			}
+			count++
+			if count == n {
+				return pos + i + 1, nil
+			}
+		}
+	}
+	return start, nil
+}
+
+// byteRing is an io.Writer that retains the last bytes written to it, up to
+// a maximum that is allocated only as it is reached.  If evict is not nil
+// bytes that are no longer retained are written to it.
+type byteRing struct {
+	buf   []byte
+	max   int64
+	off   int
+	full  bool
+	evict io.Writer
+}
+
+func newByteRing(n int64, evict io.Writer) *byteRing {
+	if n < 0 {
+		n = 0
+	}
+	return &byteRing{max: n, evict: evict}
+}
+
+func (r *byteRing) Write(p []byte) (int, error) {
+	n := len(p)
+	if !r.full {
+		k := r.max - int64(len(r.buf))
+		if int64(len(p)) < k {
+			r.buf = append(r.buf, p...)
+			return n, nil
+		}
+		r.buf = append(r.buf, p[:k]...)
+		p = p[k:]
+		r.full = true
+	}
+	if len(r.buf) == 0 {
+		if r.evict != nil {
+			return r.evict.Write(p)
+		}
+		return n, nil
+	}
+	if r.evict == nil && len(p) > len(r.buf) {
+		p = p[len(p)-len(r.buf):]
+	}
+	for len(p) > 0 {
+		k := len(r.buf) - r.off
+		if k > len(p) {
+			k = len(p)
+		}
+		if r.evict != nil {
+			_, err := r.evict.Write(r.buf[r.off : r.off+k])
+			if err != nil {
+				return n - len(p), err
+			}
+		}
+		copy(r.buf[r.off:], p[:k])
+		p = p[k:]
+		r.off = (r.off + k) % len(r.buf)
+	}
+	return n, nil
+}
+
+// Bytes returns a copy of the retained bytes in the order they were written.
+func (r *byteRing) Bytes() []byte {
+	b := make([]byte, 0, len(r.buf))
+	b = append(b, r.buf[r.off:]...)
+	return append(b, r.buf[:r.off]...)
+}
+
+// WriteTo writes the retained bytes to w in the order they were written.
+func (r *byteRing) WriteTo(w io.Writer) (int64, error) {
+	k, err := w.Write(r.buf[r.off:])
+	n := int64(k)
+	if err != nil {
+		return n, err
+	}
+	k, err = w.Write(r.buf[:r.off])
+	return n + int64(k), err
+}
@@ -0,0 +1,456 @@
+package nx
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/pipe.v2"
+)
+
+// errPipeTimeout is returned by runTimeout when a pipe does not complete, as
+// happens when a pipe reads or writes its streams outside of a task.
+var errPipeTimeout = errors.New("pipe did not complete")
+
+// runTimeout runs p with s, along with the tasks it registers, using runState.
+// The stdin and stdout of s block until p has been set up, so a pipe that uses
+// its streams outside of a task fails with errPipeTimeout, as it would
+// deadlock in a pipe.Line.  An *os.File stdin is not gated, so that pipes can
+// still recognize a regular file and seek within it.
+func runTimeout(p pipe.Pipe, s *pipe.State) error {
+	open := make(chan struct{})
+	if _, ok := s.Stdin.(*os.File); !ok {
+		s.Stdin = &gatedReader{s.Stdin, open}
+	}
+	s.Stdout = &gatedWriter{s.Stdout, open}
+	errc := make(chan error, 1)
+	setup := func(s *pipe.State) error {
+		defer close(open)
+		return p(s)
+	}
+	go func() {
+		errc <- runState(setup, s)
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-time.After(10 * time.Second):
+		return errPipeTimeout
+	}
+}
+
+// gatedReader blocks reads from r until open is closed.
+type gatedReader struct {
+	r    io.Reader
+	open chan struct{}
+}
+
+func (g *gatedReader) Read(b []byte) (int, error) {
+	<-g.open
+	return g.r.Read(b)
+}
+
+// gatedWriter blocks writes to w until open is closed.
+type gatedWriter struct {
+	w    io.Writer
+	open chan struct{}
+}
+
+func (g *gatedWriter) Write(b []byte) (int, error) {
+	<-g.open
+	return g.w.Write(b)
+}
+
+// newState returns a pipe.State reading in from stdin and writing to stdout.
+func newState(in io.Reader, stdout io.Writer) *pipe.State {
+	s := pipe.NewState(stdout, nil)
+	if in != nil {
+		s.Stdin = in
+	}
+	return s
+}
+
+func runPipe(p pipe.Pipe, in string) (string, error) {
+	out := &pipe.OutputBuffer{}
+	err := runTimeout(p, newState(strings.NewReader(in), out))
+	return string(out.Bytes()), err
+}
+
+// runFileAndStream runs p with in as stdin, both as a regular file and as a
+// stream, and returns the output of each.
+func runFileAndStream(t testing.TB, p pipe.Pipe, in string) (file, stream string) {
+	f, err := ioutil.TempFile("", "nx-input-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	_, err = io.WriteString(f, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := &pipe.OutputBuffer{}
+	err = runTimeout(p, newState(f, out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file = string(out.Bytes())
+
+	stream, err = runPipe(p, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file, stream
+}
+
+func TestLast(t *testing.T) {
+	long := strings.Repeat("x", 100<<10)
+	for i, test := range []struct {
+		n       int
+		in, out string
+	}{
+		{2, "a\nb\nc\n", "b\nc\n"},
+		{2, "a\nb\nc", "b\nc"},
+		{2, "a\n\n\n", "\n\n"},
+		{5, "a\nb\n", "a\nb\n"},
+		{1, "", ""},
+		{0, "a\nb\n", ""},
+		{2, long + "\n" + long + "\nend\n", long + "\nend\n"},
+	} {
+		file, stream := runFileAndStream(t, Last(test.n), test.in)
+		if file != test.out {
+			t.Errorf("test %d: file %.20q (expected %.20q)", i, file, test.out)
+		}
+		if stream != test.out {
+			t.Errorf("test %d: stream %.20q (expected %.20q)", i, stream, test.out)
+		}
+	}
+}
+
+func TestTrimLast(t *testing.T) {
+	for i, test := range []struct {
+		n       int
+		in, out string
+	}{
+		{2, "a\nb\nc\n", "a\n"},
+		{1, "a\nb\nc", "a\nb\n"},
+		{5, "a\nb\n", ""},
+		{0, "a\nb\n", "a\nb\n"},
+	} {
+		file, stream := runFileAndStream(t, TrimLast(test.n), test.in)
+		if file != test.out {
+			t.Errorf("test %d: file %q (expected %q)", i, file, test.out)
+		}
+		if stream != test.out {
+			t.Errorf("test %d: stream %q (expected %q)", i, stream, test.out)
+		}
+	}
+}
+
+func TestLastFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nx-last-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(filepath.Join(dir, "f"), []byte("a--b--c--"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, test := range []struct {
+		opt LineOptions
+		out string
+	}{
+		{LineOptions{}, "a--b--c--"},
+		{LineOptions{Delim: []byte("-")}, "c--"},
+		{LineOptions{Delim: []byte("--")}, "b--c--"},
+	} {
+		out := &pipe.OutputBuffer{}
+		s := newState(nil, out)
+		s.Dir = dir
+		err := runTimeout(LastFile("f", 2, test.opt), s)
+		if err != nil || string(out.Bytes()) != test.out {
+			t.Errorf("test %d: output %q, error %v (expected %q)", i, out.Bytes(), err, test.out)
+		}
+	}
+}
+
+func TestLastBytes(t *testing.T) {
+	for i, test := range []struct {
+		n       int64
+		in, out string
+	}{
+		{3, "hello", "llo"},
+		{10, "hello", "hello"},
+		{0, "hello", ""},
+	} {
+		file, stream := runFileAndStream(t, LastBytes(test.n), test.in)
+		if file != test.out {
+			t.Errorf("test %d: file %q (expected %q)", i, file, test.out)
+		}
+		if stream != test.out {
+			t.Errorf("test %d: stream %q (expected %q)", i, stream, test.out)
+		}
+	}
+}
+
+// TestLast_seek checks that pipes given a regular file as stdin seek to the
+// data they need.  The input is a sparse file too large to read in time.
+func TestLast_seek(t *testing.T) {
+	f, err := ioutil.TempFile("", "nx-sparse-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	const size = 1 << 40
+	err = f.Truncate(size)
+	if err != nil {
+		t.Skipf("sparse file: %v", err)
+	}
+	_, err = f.WriteAt([]byte("\nend\n"), size-5)
+	if err != nil {
+		t.Skipf("sparse file: %v", err)
+	}
+	for i, p := range []pipe.Pipe{
+		Last(1),
+		LastBytes(4),
+		LastRunes(4),
+		TrimFirstBytes(size - 4),
+	} {
+		_, err := f.Seek(0, io.SeekStart)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := &pipe.OutputBuffer{}
+		err = runTimeout(p, newState(f, out))
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+		} else if string(out.Bytes()) != "end\n" {
+			t.Errorf("test %d: output %q", i, out.Bytes())
+		}
+	}
+}
+
+const benchmarkLastSize = 64 << 20
+
+func benchmarkLast(b *testing.B, p pipe.Pipe, file bool) {
+	f, err := ioutil.TempFile("", "nx-input-")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	var size int64
+	for i := 0; size < benchmarkLastSize; i++ {
+		n, _ := fmt.Fprintf(w, "line %d of the benchmark input\n", i)
+		size += int64(n)
+	}
+	err = w.Flush()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetBytes(size)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := f.Seek(0, io.SeekStart)
+		if err != nil {
+			b.Fatal(err)
+		}
+		var stdin io.Reader = f
+		if !file {
+			stdin = onlyReader{f}
+		}
+		// The file is handed to p directly so that it can seek.
+		err = runState(p, newState(stdin, nil))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLast_file(b *testing.B)       { benchmarkLast(b, Last(10), true) }
+func BenchmarkLast_stream(b *testing.B)     { benchmarkLast(b, Last(10), false) }
+func BenchmarkTrimLast_file(b *testing.B)   { benchmarkLast(b, TrimLast(10), true) }
+func BenchmarkTrimLast_stream(b *testing.B) { benchmarkLast(b, TrimLast(10), false) }
+
+func TestLast_options(t *testing.T) {
+	file, stream := runFileAndStream(t, Last(2, NULLines), "a\x00b\nc\x00d\x00")
+	if file != "b\nc\x00d\x00" || stream != file {
+		t.Errorf("file %q stream %q", file, stream)
+	}
+	file, stream = runFileAndStream(t, TrimLast(1, LineOptions{Delim: []byte("--")}), "a--b--c--")
+	if file != "a--b--" || stream != file {
+		t.Errorf("file %q stream %q", file, stream)
+	}
+}
+
+func TestLastLinesOffset(t *testing.T) {
+	for i, test := range []struct {
+		in    string
+		start int64
+		n     int
+		off   int64
+	}{
+		{"a\nb\nc\n", 0, 2, 2},
+		{"a\nb\n\n\n", 0, 2, 4},
+		{"a\nb\n\n\n", 0, 3, 2},
+		{"a\nb\nc", 0, 1, 4},
+		{"a\nb\nc", 0, 2, 2},
+		{"a\nb\n", 0, 5, 0},
+		{"a\nb\nc\n", 2, 5, 2},
+		{"a\nb\n", 0, 0, 4},
+		{"", 0, 1, 0},
+		{"\n", 0, 1, 0},
+	} {
+		off, err := lastLinesOffset(strings.NewReader(test.in), test.start, int64(len(test.in)), test.n, '\n')
+		if err != nil || off != test.off {
+			t.Errorf("test %d: offset %d, error %v (expected %d)", i, off, err, test.off)
+		}
+	}
+}
+
+func TestCopyLastLines(t *testing.T) {
+	f, err := ioutil.TempFile("", "nx-input-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	for i, test := range []struct {
+		in    string
+		start int64
+		n     int
+		out   string
+	}{
+		{"a\nb\nc\n", 0, 2, "b\nc\n"},
+		{"a\nb\n\n\n", 0, 2, "\n\n"},
+		{"a\nb\nc", 0, 2, "b\nc"},
+		{"a\nb\n", 0, 5, "a\nb\n"},
+		{"a\nb\nc\n", 2, 5, "b\nc\n"},
+		{"a\nb\n", 10, 1, ""},
+		{"", 0, 1, ""},
+	} {
+		err := f.Truncate(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = f.WriteAt([]byte(test.in), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = f.Seek(test.start, io.SeekStart)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		err = copyLastLines(&out, f, test.n, '\n')
+		if err != nil || out.String() != test.out {
+			t.Errorf("test %d: output %q, error %v (expected %q)", i, out.String(), err, test.out)
+		}
+		pos, err := f.Seek(0, io.SeekCurrent)
+		if err != nil || pos != int64(len(test.in)) {
+			t.Errorf("test %d: offset %d, error %v (expected %d)", i, pos, err, len(test.in))
+		}
+	}
+}
+
+func TestSeekTail(t *testing.T) {
+	for i, test := range []struct {
+		in    string
+		start int64
+		n     int64
+		off   int64
+	}{
+		{"hello", 0, 3, 2},
+		{"hello", 0, 10, 0},
+		{"hello", 4, 3, 4},
+		{"hello", 0, 0, 5},
+		{"", 0, 1, 0},
+	} {
+		r := strings.NewReader(test.in)
+		_, err := r.Seek(test.start, io.SeekStart)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = seekTail(r, test.n)
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+			continue
+		}
+		off, _ := r.Seek(0, io.SeekCurrent)
+		if off != test.off {
+			t.Errorf("test %d: offset %d (expected %d)", i, off, test.off)
+		}
+	}
+}
+
+func TestBytesAndRunes(t *testing.T) {
+	for i, test := range []struct {
+		p       pipe.Pipe
+		in, out string
+	}{
+		{TrimFirstBytes(2), "hello", "llo"},
+		{TrimFirstBytes(10), "hello", ""},
+		{TrimLastBytes(2), "hello", "hel"},
+		{TrimLastBytes(10), "hello", ""},
+		{TrimLastBytes(0), "hello", "hello"},
+		{FirstRunes(2), "héllo", "hé"},
+		{FirstRunes(3), "a\xffbc", "a\xffb"},
+		{FirstRunes(10), "日本語", "日本語"},
+		{TrimFirstRunes(2), "日本語", "語"},
+		{LastRunes(2), "日本語", "本語"},
+		{LastRunes(2), "ab\xff", "b\xff"},
+		{LastRunes(5), "日本語", "日本語"},
+		{TrimLastRunes(1), "日本語", "日本"},
+		{TrimLastRunes(1), "héllo wörld", "héllo wörl"},
+		{TrimLastRunes(5), "日本語", ""},
+		{LastBytes(1 << 40), "hello", "hello"},
+		{TrimLastBytes(1 << 40), "hello", ""},
+		{LastRunes(1 << 62), "日本語", "日本語"},
+		{TrimLastRunes(1 << 62), "日本語", ""},
+	} {
+		file, stream := runFileAndStream(t, test.p, test.in)
+		if file != test.out {
+			t.Errorf("test %d: file %q (expected %q)", i, file, test.out)
+		}
+		if stream != test.out {
+			t.Errorf("test %d: stream %q (expected %q)", i, stream, test.out)
+		}
+	}
+}
+
+func TestByteRing_evict(t *testing.T) {
+	var evicted bytes.Buffer
+	ring := newByteRing(3, &evicted)
+	for _, s := range []string{"ab", "cdefg", "h"} {
+		ring.Write([]byte(s))
+	}
+	if evicted.String() != "abcde" {
+		t.Errorf("evicted %q", evicted.String())
+	}
+	if string(ring.Bytes()) != "fgh" {
+		t.Errorf("retained %q", ring.Bytes())
+	}
+
+	ring = newByteRing(1<<40, nil)
+	ring.Write([]byte("abc"))
+	if cap(ring.buf) > 64 || string(ring.Bytes()) != "abc" {
+		t.Errorf("retained %q in %d bytes", ring.Bytes(), cap(ring.buf))
+	}
+}
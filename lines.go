@@ -0,0 +1,269 @@
+package nx
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"regexp"
+	"unicode/utf8"
+
+	"gopkg.in/pipe.v2"
+)
+
+// ErrLineTooLong is returned by line-oriented pipes when a line exceeds
+// LineOptions.MaxLen.
+var ErrLineTooLong = errors.New("nx: line too long")
+
+// LineOptions determines how line-oriented pipes split their input into
+// lines.  The zero value splits lines terminated by '\n' of any length.
+// Line-oriented pipes emit lines with the same terminator they were read with
+// and a final line lacking a terminator is emitted without one.
+type LineOptions struct {
+	// Delim is the sequence of bytes terminating lines.  If Delim is empty
+	// lines are terminated by '\n'.
+	Delim []byte
+
+	// Regexp matches line terminators.  Empty matches are ignored.  If
+	// Regexp is not nil Delim is ignored.
+	Regexp *regexp.Regexp
+
+	// KeepCR causes the '\r' preceding a '\n' terminator to be considered
+	// part of the line when lines are passed to functions such as those
+	// given to Filter and Replace.  By default "\r\n" terminates a line when
+	// lines are terminated by '\n'.
+	KeepCR bool
+
+	// MaxLen is the maximum length of a line in bytes, excluding its
+	// terminator.  Longer lines cause ErrLineTooLong.  If MaxLen is not
+	// positive the length of lines is not limited.
+	MaxLen int
+}
+
+// NULLines splits input into lines terminated by NUL bytes, like the -z flag
+// of many commands.
+var NULLines = LineOptions{Delim: []byte{0}}
+
+// lineOptions returns the options passed to a line-oriented pipe.  When
+// multiple options are given the last is used.
+func lineOptions(opts []LineOptions) LineOptions {
+	if len(opts) == 0 {
+		return LineOptions{}
+	}
+	return opts[len(opts)-1]
+}
+
+// seekDelim returns the byte terminating lines and true if lines can be
+// located by searching backwards from the end of a file for a single byte.
+func (opt LineOptions) seekDelim() (byte, bool) {
+	if opt.Regexp != nil || opt.MaxLen > 0 {
+		return 0, false
+	}
+	switch len(opt.Delim) {
+	case 0:
+		return '\n', true
+	case 1:
+		return opt.Delim[0], true
+	}
+	return 0, false
+}
+
+// Filter returns a pipe.Pipe that emits lines read from stdin for which f
+// returns true, like pipe.Filter.  The line given to f does not include its
+// terminator.
+func Filter(f func(line []byte) bool, opts ...LineOptions) pipe.Pipe {
+	opt := lineOptions(opts)
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		r := newLineReader(p.Stdin, opt)
+		w := bufio.NewWriter(p.Stdout)
+		for {
+			line, term, err := r.next()
+			if err == io.EOF {
+				return w.Flush()
+			}
+			if err != nil {
+				return err
+			}
+			if !f(line) {
+				continue
+			}
+			_, err = w.Write(line)
+			if err == nil {
+				_, err = w.Write(term)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// Replace returns a pipe.Pipe that emits the result of calling f on each line
+// read from stdin, like pipe.Replace.  The line given to f does not include
+// its terminator, which is written after the value f returns.
+func Replace(f func(line []byte) []byte, opts ...LineOptions) pipe.Pipe {
+	opt := lineOptions(opts)
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		r := newLineReader(p.Stdin, opt)
+		w := bufio.NewWriter(p.Stdout)
+		for {
+			line, term, err := r.next()
+			if err == io.EOF {
+				return w.Flush()
+			}
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(f(line))
+			if err == nil {
+				_, err = w.Write(term)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// lineReader reads lines according to LineOptions.
+type lineReader struct {
+	r   *bufio.Reader
+	opt LineOptions
+	buf []byte // line data for single byte delimiters, unsplit data otherwise
+	eof bool
+}
+
+func newLineReader(r io.Reader, opt LineOptions) *lineReader {
+	return &lineReader{r: bufio.NewReader(r), opt: opt}
+}
+
+// next returns the next line and its terminator.  The final line may have an
+// empty terminator.  The returned slices are only valid until the next call to
+// next.  When no lines remain next returns io.EOF.
+func (l *lineReader) next() (line, term []byte, err error) {
+	if l.opt.Regexp != nil {
+		line, term, err = l.nextRegexp()
+	} else {
+		line, term, err = l.nextDelim()
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if !l.opt.KeepCR && len(term) == 1 && term[0] == '\n' && l.opt.Regexp == nil {
+		if n := len(line); n > 0 && line[n-1] == '\r' {
+			line, term = line[:n-1], line[n-1:n+1]
+		}
+	}
+	return line, term, nil
+}
+
+// full returns true if n bytes of line data exceeds MaxLen.
+func (l *lineReader) full(n int) bool {
+	return l.opt.MaxLen > 0 && n > l.opt.MaxLen
+}
+
+func (l *lineReader) nextDelim() (line, term []byte, err error) {
+	delim := l.opt.Delim
+	if len(delim) == 0 {
+		delim = []byte{'\n'}
+	}
+	last := delim[len(delim)-1]
+	l.buf = l.buf[:0]
+	for {
+		chunk, err := l.r.ReadSlice(last)
+		l.buf = append(l.buf, chunk...)
+		if err == nil && bytes.HasSuffix(l.buf, delim) {
+			n := len(l.buf) - len(delim)
+			if l.full(n) {
+				return nil, nil, ErrLineTooLong
+			}
+			return l.buf[:n], l.buf[n:], nil
+		}
+		if l.full(len(l.buf) - len(delim) + 1) {
+			return nil, nil, ErrLineTooLong
+		}
+		if err == io.EOF {
+			if len(l.buf) == 0 {
+				return nil, nil, io.EOF
+			}
+			return l.buf, nil, nil
+		}
+		if err != nil && err != bufio.ErrBufferFull {
+			return nil, nil, err
+		}
+	}
+}
+
+func (l *lineReader) nextRegexp() (line, term []byte, err error) {
+	for {
+		loc := l.matchRegexp()
+		// a match ending at the end of buffered data may continue in data
+		// not yet read.
+		if loc != nil && (loc[1] < len(l.buf) || l.eof) {
+			line = l.buf[:loc[0]:loc[0]]
+			term = l.buf[loc[0]:loc[1]:loc[1]]
+			l.buf = l.buf[loc[1]:]
+			if l.full(len(line)) {
+				return nil, nil, ErrLineTooLong
+			}
+			return line, term, nil
+		}
+		if l.eof {
+			if len(l.buf) == 0 {
+				return nil, nil, io.EOF
+			}
+			line, l.buf = l.buf, nil
+			if l.full(len(line)) {
+				return nil, nil, ErrLineTooLong
+			}
+			return line, nil, nil
+		}
+		if loc == nil && l.full(len(l.buf)) {
+			return nil, nil, ErrLineTooLong
+		}
+		err := l.fill()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+// matchRegexp returns the location of the first non-empty match of Regexp in
+// the buffered data.  The search stops at the first such match, so that
+// splitting buffered data into lines takes time linear in its length.
+func (l *lineReader) matchRegexp() []int {
+	off := 0
+	for off <= len(l.buf) {
+		loc := l.opt.Regexp.FindIndex(l.buf[off:])
+		if loc == nil {
+			return nil
+		}
+		if loc[1] > loc[0] {
+			return []int{off + loc[0], off + loc[1]}
+		}
+		// search again past the rune following the empty match
+		_, size := utf8.DecodeRune(l.buf[off+loc[1]:])
+		if size == 0 {
+			return nil
+		}
+		off += loc[1] + size
+	}
+	return nil
+}
+
+// fill appends more input to l.buf, compacting it first.
+func (l *lineReader) fill() error {
+	size := 2 * len(l.buf)
+	if size < len(l.buf)+4096 {
+		size = len(l.buf) + 4096
+	}
+	buf := make([]byte, len(l.buf), size)
+	copy(buf, l.buf)
+	n, err := l.r.Read(buf[len(buf):cap(buf)])
+	l.buf = buf[:len(buf)+n]
+	if err == io.EOF {
+		l.eof = true
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,88 @@
+package nx
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+
+	"gopkg.in/pipe.v2"
+)
+
+func TestLineOptions(t *testing.T) {
+	upper := func(ln []byte) []byte { return bytes.ToUpper(ln) }
+	long := strings.Repeat("x", 100<<10)
+	for i, test := range []struct {
+		opt     LineOptions
+		in, out string
+		err     error
+	}{
+		{LineOptions{}, "a\nb", "A\nB", nil},
+		{LineOptions{}, long + "\n", strings.ToUpper(long) + "\n", nil},
+		{NULLines, "a\x00b\nc\x00", "A\x00B\nC\x00", nil},
+		{LineOptions{Delim: []byte("--")}, "a-b--c--", "A-B--C--", nil},
+		{LineOptions{Regexp: regexp.MustCompile(`[,;]+`)}, "a,b;;c", "A,B;;C", nil},
+		{LineOptions{Regexp: regexp.MustCompile(`x*`)}, "axxb", "AxxB", nil},
+		{LineOptions{Regexp: regexp.MustCompile(`x*`)}, "éxxéx", "ÉxxÉx", nil},
+		{LineOptions{Regexp: regexp.MustCompile(`,`)}, strings.Repeat("a,", 1<<16), strings.Repeat("A,", 1<<16), nil},
+		{LineOptions{MaxLen: 3}, "abc\nabcd\n", "", ErrLineTooLong},
+		{LineOptions{MaxLen: 3, Regexp: regexp.MustCompile(`\n`)}, "abc\nabcd", "", ErrLineTooLong},
+	} {
+		out, err := runPipe(Replace(upper, test.opt), test.in)
+		if err != test.err {
+			t.Errorf("test %d: error %v (expected %v)", i, err, test.err)
+		}
+		if out != test.out {
+			t.Errorf("test %d: %.20q (expected %.20q)", i, out, test.out)
+		}
+	}
+}
+
+func TestLineOptions_KeepCR(t *testing.T) {
+	hasCR := func(ln []byte) bool { return bytes.HasSuffix(ln, []byte("\r")) }
+	out, err := runPipe(Filter(hasCR), "a\r\nb\n")
+	if err != nil || out != "" {
+		t.Errorf("default: %q %v", out, err)
+	}
+	out, err = runPipe(Filter(hasCR, LineOptions{KeepCR: true}), "a\r\nb\n")
+	if err != nil || out != "a\r\n" {
+		t.Errorf("keep: %q %v", out, err)
+	}
+}
+
+func TestFilter_line(t *testing.T) {
+	notB := func(ln []byte) bool { return !bytes.Equal(ln, []byte("b")) }
+	out := &pipe.OutputBuffer{}
+	p := pipe.Line(
+		pipe.Print("a\nb\nc\n"),
+		Filter(notB),
+		Replace(bytes.ToUpper),
+		First(1),
+	)
+	err := runTimeout(p, newState(nil, out))
+	if err != nil || string(out.Bytes()) != "A\n" {
+		t.Errorf("output %q, error %v", out.Bytes(), err)
+	}
+}
+
+func TestFirst(t *testing.T) {
+	for i, test := range []struct {
+		p       pipe.Pipe
+		in, out string
+	}{
+		{First(2), "a\r\nb\nc\n", "a\r\nb\n"},
+		{First(5), "a\nb", "a\nb"},
+		{First(1, NULLines), "a\x00b\x00", "a\x00"},
+		{TrimFirst(1), "a\nb\nc", "b\nc"},
+		{TrimFirst(5), "a\nb\n", ""},
+		{TrimFirst(1, LineOptions{Regexp: regexp.MustCompile(`;`)}), "a;b;c", "b;c"},
+	} {
+		out, err := runPipe(test.p, test.in)
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+		}
+		if out != test.out {
+			t.Errorf("test %d: %q (expected %q)", i, out, test.out)
+		}
+	}
+}
@@ -10,7 +10,6 @@ package nx
 
 import (
 	"bufio"
-	"fmt"
 	"io"
 	"io/ioutil"
 
@@ -18,42 +17,78 @@ import (
 )
 
 // First returns a pipe.Pipe that emits the first n lines of input read from
-// stdin to stdout.
-func First(n int) pipe.Pipe {
-	return func(p *pipe.State) error {
-		s := bufio.NewScanner(p.Stdin)
+// stdin to stdout.  Lines are split according to opts, see LineOptions.
+func First(n int, opts ...LineOptions) pipe.Pipe {
+	opt := lineOptions(opts)
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		r := newLineReader(p.Stdin, opt)
+		w := bufio.NewWriter(p.Stdout)
 		for i := 0; i < n; i++ {
-			if !s.Scan() {
-				return s.Err()
+			line, term, err := r.next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(line)
+			if err == nil {
+				_, err = w.Write(term)
 			}
-			_, err := fmt.Fprintln(p.Stdout, s.Text())
 			if err != nil {
 				return err
 			}
 		}
-		return nil
-	}
+		return w.Flush()
+	})
 }
 
 // FirstBytes returns a pipe.Pipe that emits the first n bytes of input read
 // from stdin to stdout.
 func FirstBytes(n int64) pipe.Pipe {
-	return func(p *pipe.State) error {
+	return pipe.TaskFunc(func(p *pipe.State) error {
 		_, err := io.Copy(p.Stdout, io.LimitReader(p.Stdin, n))
 		return err
+	})
+}
+
+// TrimFirstBytes returns a pipe.Pipe that emits all but the first n bytes
+// read from stdin to stdout, like `tail -c +n+1`.
+func TrimFirstBytes(n int64) pipe.Pipe {
+	if n <= 0 {
+		return pipe.Tee(ioutil.Discard)
 	}
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		if f, ok := regularFile(p.Stdin); ok {
+			_, err := f.Seek(n, io.SeekCurrent)
+			if err != nil {
+				return err
+			}
+		} else {
+			_, err := io.CopyN(ioutil.Discard, p.Stdin, n)
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+		_, err := io.Copy(p.Stdout, p.Stdin)
+		return err
+	})
 }
 
 // TrimFirst returns a pipe.Pipe that emits all but the first n lines read from
-// stdin to stdout.
-func TrimFirst(n int) pipe.Pipe {
-	if n == 0 {
+// stdin to stdout.  Lines are split according to opts, see LineOptions.
+func TrimFirst(n int, opts ...LineOptions) pipe.Pipe {
+	if n <= 0 {
 		return pipe.Tee(ioutil.Discard)
 	}
-	return func(p *pipe.State) error {
-		r := bufio.NewReader(p.Stdin)
-		for i := 0; i < -n; i++ {
-			_, err := r.ReadBytes('\n')
+	opt := lineOptions(opts)
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		r := newLineReader(p.Stdin, opt)
+		for i := 0; i < n; i++ {
+			_, _, err := r.next()
 			if err == io.EOF {
 				return nil
 			}
@@ -61,93 +96,91 @@ func TrimFirst(n int) pipe.Pipe {
 				return err
 			}
 		}
-		_, err := io.Copy(p.Stdout, r)
+		if opt.Regexp != nil {
+			// bytes following the last terminator are already buffered.
+			_, err := p.Stdout.Write(r.buf)
+			if err != nil {
+				return err
+			}
+		}
+		_, err := io.Copy(p.Stdout, r.r)
 		return err
-	}
+	})
 }
 
 // TrimLast returns a pipe.Pipe that emits all but the last n lines read from
-// stdin to stdout.
-func TrimLast(n int) pipe.Pipe {
-	if n == 0 {
+// stdin to stdout.  If stdin is a regular file and lines are terminated by a
+// single byte TrimLast locates the last n lines without scanning the entire
+// file.  Lines are split according to opts, see LineOptions.
+func TrimLast(n int, opts ...LineOptions) pipe.Pipe {
+	if n <= 0 {
 		return pipe.Tee(ioutil.Discard)
 	}
-	return func(p *pipe.State) error {
+	opt := lineOptions(opts)
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		if delim, ok := opt.seekDelim(); ok {
+			if f, ok := regularFile(p.Stdin); ok {
+				return copyFileRange(p.Stdout, f, func(start, size int64) (int64, int64, error) {
+					off, err := lastLinesOffset(f, start, size, n, delim)
+					return start, off, err
+				})
+			}
+		}
 		var i int
 		var full bool
 		buf := make([][]byte, n)
 		w := bufio.NewWriter(p.Stdout)
-		s := bufio.NewScanner(p.Stdin)
-		for s.Scan() {
+		r := newLineReader(p.Stdin, opt)
+		for {
+			line, term, err := r.next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
 			if full {
 				_, err := w.Write(buf[i])
 				if err != nil {
 					return err
 				}
-				_, err = w.Write([]byte{'\n'})
-				if err != nil {
-					return err
-				}
 			}
-			buf[i] = append(buf[i][:0], s.Bytes()...)
-			i %= n
+			buf[i] = append(append(buf[i][:0], line...), term...)
+			i = (i + 1) % n
 			full = full || i == 0
 		}
-		err := s.Err()
-		if err != nil {
-			return err
-		}
 		return w.Flush()
-	}
+	})
 }
 
 // Last returns a pipe.Pipe that emits the last n lines read from stdin before
-// encountering EOF to stdout.
-func Last(n int) pipe.Pipe {
-	return func(p *pipe.State) error {
-		if n == 0 {
-			return nil
-		}
-
-		lnbuf := make([]string, n)
-		var i int
-		s := bufio.NewScanner(p.Stdin)
-		for {
-			if !s.Scan() {
-				break
-			}
-			lnbuf[i] = s.Text()
-		}
-		err := s.Err()
-		if err != nil {
-			return err
-		}
-		for j := 0; j < n; j++ {
-			ln := lnbuf[(i+j)%n]
-			_, err := fmt.Fprintln(p.Stdout, ln)
-			if err != nil {
-				return err
+// encountering EOF to stdout.  If stdin is a regular file and lines are
+// terminated by a single byte Last reads only the end of the file.  Lines are
+// split according to opts, see LineOptions.
+func Last(n int, opts ...LineOptions) pipe.Pipe {
+	opt := lineOptions(opts)
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		if delim, ok := opt.seekDelim(); ok {
+			if f, ok := regularFile(p.Stdin); ok {
+				return copyLastLines(p.Stdout, f, n, delim)
 			}
 		}
-		return nil
-	}
+		return writeLastLines(p.Stdout, p.Stdin, n, opt)
+	})
 }
 
-func Varargs(f func(args []string) pipe.Pipe) pipe.Pipe {
-	return func(p *pipe.State) error {
-		var args []string
-		s := bufio.NewScanner(p.Stdin)
-		s.Split(bufio.ScanWords)
-		for {
-			if !s.Scan() {
-				break
-			}
-			args = append(args, s.Text())
-		}
-		err := s.Err()
+// writeLastLines writes the last n lines read from r to w.
+func writeLastLines(w io.Writer, r io.Reader, n int, opt LineOptions) error {
+	lines, err := lastLines(newLineReader(r, opt), n)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	for _, ln := range lines {
+		_, err := bw.Write(ln)
 		if err != nil {
 			return err
 		}
-		return f(args)(p)
 	}
+	return bw.Flush()
 }
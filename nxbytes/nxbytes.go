@@ -3,55 +3,62 @@ package nxbytes
 import (
 	"bytes"
 
+	"github.com/bmatsuo/nx"
 	"gopkg.in/pipe.v2"
 )
 
 // Contains returns a pipe.Pipe that emits lines read from stdin that contain
 // subslice.  Because Contains returns a line-oriented pipe.Pipe subslice must
-// not contain a newline.
-func Contains(subslice []byte) pipe.Pipe {
-	return pipe.Filter(func(ln []byte) bool {
+// not contain a line terminator.  Lines are split according to opts, see
+// nx.LineOptions.
+func Contains(subslice []byte, opts ...nx.LineOptions) pipe.Pipe {
+	return nx.Filter(func(ln []byte) bool {
 		return bytes.Contains(ln, subslice)
-	})
+	}, opts...)
 }
 
 // HasSuffix returns a pipe.Pipe that emits lines read from stdin that end with
 // suffix.  HasSuffix does not count the newline '\n' bytes separating input
-// lines.
-func HasSuffix(suffix []byte) pipe.Pipe {
-	return pipe.Filter(func(ln []byte) bool {
-		return bytes.HasPrefix(ln, suffix)
-	})
+// lines.  Lines are split according to opts, see nx.LineOptions.
+func HasSuffix(suffix []byte, opts ...nx.LineOptions) pipe.Pipe {
+	return nx.Filter(func(ln []byte) bool {
+		return bytes.HasSuffix(ln, suffix)
+	}, opts...)
 }
 
 // HasPrefix returns a pipe.Pipe that emits lines read from stdin that begin
-// with prefix.
-func HasPrefix(prefix []byte) pipe.Pipe {
-	return pipe.Filter(func(ln []byte) bool {
+// with prefix.  Lines are split according to opts, see nx.LineOptions.
+func HasPrefix(prefix []byte, opts ...nx.LineOptions) pipe.Pipe {
+	return nx.Filter(func(ln []byte) bool {
 		return bytes.HasPrefix(ln, prefix)
-	})
+	}, opts...)
 }
 
 // TrimSuffix returns a pipe.Pipe that emits lines read from stdin, removing
 // suffix from output lines if it is found suffixing input.  HasSuffix does not
-// count the newline '\n' bytes separating input lines.
-func TrimSuffix(suffix []byte) pipe.Pipe {
-	return pipe.Replace(func(ln []byte) []byte {
+// count the newline '\n' bytes separating input lines.  Lines are split
+// according to opts, see nx.LineOptions.
+func TrimSuffix(suffix []byte, opts ...nx.LineOptions) pipe.Pipe {
+	return nx.Replace(func(ln []byte) []byte {
 		return bytes.TrimSuffix(ln, suffix)
-	})
+	}, opts...)
 }
 
 // TrimPrefix returns a pipe.Pipe that emits lines read from stdin, removing
 // prefix from output lines if it is found prefixing input.  HasSuffix does not
-// count the newline '\n' bytes separating input lines.
-func TrimPrefix(prefix []byte) pipe.Pipe {
-	return pipe.Replace(func(ln []byte) []byte {
+// count the newline '\n' bytes separating input lines.  Lines are split
+// according to opts, see nx.LineOptions.
+func TrimPrefix(prefix []byte, opts ...nx.LineOptions) pipe.Pipe {
+	return nx.Replace(func(ln []byte) []byte {
 		return bytes.TrimPrefix(ln, prefix)
-	})
+	}, opts...)
 }
 
-func ReplaceAll(sub, repl []byte) pipe.Pipe {
-	return pipe.Replace(func(ln []byte) []byte {
+// ReplaceAll returns a pipe.Pipe that emits lines read from stdin with all
+// instances of sub replaced by repl.  Lines are split according to opts, see
+// nx.LineOptions.
+func ReplaceAll(sub, repl []byte, opts ...nx.LineOptions) pipe.Pipe {
+	return nx.Replace(func(ln []byte) []byte {
 		return bytes.Replace(ln, sub, repl, -1)
-	})
+	}, opts...)
 }
@@ -0,0 +1,31 @@
+package nxbytes_test
+
+import (
+	"testing"
+
+	"github.com/bmatsuo/nx"
+	"github.com/bmatsuo/nx/nxbytes"
+	"gopkg.in/pipe.v2"
+)
+
+func TestPipes(t *testing.T) {
+	for i, test := range []struct {
+		p       pipe.Pipe
+		in, out string
+	}{
+		{nxbytes.Contains([]byte("b")), "ab\nba\nc\n", "ab\nba\n"},
+		{nxbytes.HasPrefix([]byte("a")), "ab\nba\nc\n", "ab\n"},
+		{nxbytes.HasSuffix([]byte("a")), "ab\nba\nc\n", "ba\n"},
+		{nxbytes.HasSuffix([]byte("a"), nx.NULLines), "ab\x00ba\x00", "ba\x00"},
+		{nxbytes.TrimPrefix([]byte("a")), "ab\nba\n", "b\nba\n"},
+		{nxbytes.TrimSuffix([]byte("a")), "ab\nba\n", "ab\nb\n"},
+		{nxbytes.ReplaceAll([]byte("a"), []byte("x")), "aba\nc", "xbx\nc"},
+	} {
+		out, err := pipe.Output(pipe.Line(pipe.Print(test.in), test.p))
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+		} else if string(out) != test.out {
+			t.Errorf("test %d: output %q (expected %q)", i, out, test.out)
+		}
+	}
+}
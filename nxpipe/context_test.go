@@ -0,0 +1,30 @@
+package nxpipe_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bmatsuo/nx/nxpipe"
+)
+
+func TestRunContext(t *testing.T) {
+	c, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := nxpipe.RunContext(c, nxpipe.Func(func(s *nxpipe.Session) error {
+		<-s.Context.Done()
+		return s.Context.Err()
+	}))
+	if err != context.Canceled {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestOutputContext(t *testing.T) {
+	out, err := nxpipe.OutputContext(context.Background(), nxpipe.Exec("echo", "hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "hello\n" {
+		t.Errorf("unexpected output: %q", out)
+	}
+}
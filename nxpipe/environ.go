@@ -0,0 +1,248 @@
+package nxpipe
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Environ is a list of environment variables in the "KEY=value" form used by
+// os.Environ.  When a key appears more than once the last value is used.
+type Environ []string
+
+// Environ returns s.Env, or the environment of the current process if s.Env is
+// empty.
+func (s *Session) Environ() Environ {
+	if len(s.Env) == 0 {
+		return Environ(os.Environ())
+	}
+	return s.Env
+}
+
+func splitEnv(kv string) (key, value string) {
+	i := strings.Index(kv, "=")
+	if i < 0 {
+		return kv, ""
+	}
+	return kv[:i], kv[i+1:]
+}
+
+// Lookup returns the value of key and true if key is set.
+func (e Environ) Lookup(key string) (string, bool) {
+	for i := len(e) - 1; i >= 0; i-- {
+		k, v := splitEnv(e[i])
+		if k == key {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// Get returns the value of key or an empty string if key is not set.
+func (e Environ) Get(key string) string {
+	v, _ := e.Lookup(key)
+	return v
+}
+
+// Set assigns value to key, replacing any existing values.
+func (e *Environ) Set(key, value string) {
+	e.Unset(key)
+	*e = append(*e, key+"="+value)
+}
+
+// Unset removes all values of key.
+func (e *Environ) Unset(key string) {
+	env := (*e)[:0:0]
+	for _, kv := range *e {
+		k, _ := splitEnv(kv)
+		if k != key {
+			env = append(env, kv)
+		}
+	}
+	*e = env
+}
+
+// Merge sets every variable in other, overriding values in e.
+func (e *Environ) Merge(other Environ) {
+	for _, kv := range other.Strings() {
+		k, v := splitEnv(kv)
+		e.Set(k, v)
+	}
+}
+
+// Clone returns a copy of e that can be modified independently.
+func (e Environ) Clone() Environ {
+	if e == nil {
+		return nil
+	}
+	return append(Environ(nil), e...)
+}
+
+// Strings returns the variables in e without duplicates, sorted by key.
+func (e Environ) Strings() []string {
+	index := make(map[string]int, len(e))
+	var env []string
+	for _, kv := range e {
+		k, _ := splitEnv(kv)
+		if i, ok := index[k]; ok {
+			env[i] = kv
+			continue
+		}
+		index[k] = len(env)
+		env = append(env, kv)
+	}
+	sort.Slice(env, func(i, j int) bool {
+		ki, _ := splitEnv(env[i])
+		kj, _ := splitEnv(env[j])
+		return ki < kj
+	})
+	return env
+}
+
+// Int parses the value of key as an integer.  If key is not set def is
+// returned.
+func (e Environ) Int(key string, def int) (int, error) {
+	v, ok := e.Lookup(key)
+	if !ok {
+		return def, nil
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return def, fmt.Errorf("%s: %v", key, err)
+	}
+	return n, nil
+}
+
+// Bool parses the value of key as a boolean using strconv.ParseBool.  If key
+// is not set def is returned.
+func (e Environ) Bool(key string, def bool) (bool, error) {
+	v, ok := e.Lookup(key)
+	if !ok {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(strings.TrimSpace(v))
+	if err != nil {
+		return def, fmt.Errorf("%s: %v", key, err)
+	}
+	return b, nil
+}
+
+// Duration parses the value of key using time.ParseDuration.  If key is not
+// set def is returned.
+func (e Environ) Duration(key string, def time.Duration) (time.Duration, error) {
+	v, ok := e.Lookup(key)
+	if !ok {
+		return def, nil
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(v))
+	if err != nil {
+		return def, fmt.Errorf("%s: %v", key, err)
+	}
+	return d, nil
+}
+
+// Expand replaces references to variables in s with their values.  The forms
+// $KEY and ${KEY} are replaced by the value of KEY, ${KEY:-default} uses
+// default when KEY is unset or empty and ${KEY-default} uses default only when
+// KEY is unset.  The sequence $$ produces a literal '$'.  Any other use of '$'
+// is left unmodified.
+func (e Environ) Expand(s string) string {
+	if strings.IndexByte(s, '$') < 0 {
+		return s
+	}
+	var buf []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			buf = append(buf, s[i])
+			continue
+		}
+		rest := s[i+1:]
+		switch {
+		case rest[0] == '$':
+			buf = append(buf, '$')
+			i++
+		case rest[0] == '{':
+			end := closingBrace(rest)
+			if end < 0 {
+				buf = append(buf, s[i])
+				continue
+			}
+			v, ok := e.expandBraces(rest[1:end])
+			if !ok {
+				buf = append(buf, s[i])
+				continue
+			}
+			buf = append(buf, v...)
+			i += end + 1
+		default:
+			n := envNameLen(rest)
+			if n == 0 {
+				buf = append(buf, s[i])
+				continue
+			}
+			buf = append(buf, e.Get(rest[:n])...)
+			i += n
+		}
+	}
+	return string(buf)
+}
+
+// expandBraces evaluates the contents of a ${...} expression.
+func (e Environ) expandBraces(expr string) (string, bool) {
+	n := envNameLen(expr)
+	if n == 0 {
+		return "", false
+	}
+	key, op := expr[:n], expr[n:]
+	v, ok := e.Lookup(key)
+	switch {
+	case op == "":
+		return v, true
+	case strings.HasPrefix(op, ":-"):
+		if v == "" {
+			return e.Expand(op[2:]), true
+		}
+		return v, true
+	case strings.HasPrefix(op, "-"):
+		if !ok {
+			return e.Expand(op[1:]), true
+		}
+		return v, true
+	}
+	return "", false
+}
+
+// closingBrace returns the index of the '}' matching the '{' at the start of
+// s, or -1 if there is none.
+func closingBrace(s string) int {
+	var depth int
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// envNameLen returns the length of the variable name at the start of s.
+func envNameLen(s string) int {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+		case '0' <= c && c <= '9' && i > 0:
+		default:
+			return i
+		}
+	}
+	return len(s)
+}
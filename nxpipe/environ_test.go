@@ -0,0 +1,144 @@
+package nxpipe_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bmatsuo/nx/nxpipe"
+)
+
+func TestEnviron(t *testing.T) {
+	env := nxpipe.Environ{"B=1", "A=2", "B=3"}
+	if v := env.Get("B"); v != "3" {
+		t.Errorf("B=%q", v)
+	}
+	if _, ok := env.Lookup("C"); ok {
+		t.Errorf("C is set")
+	}
+
+	cp := env.Clone()
+	cp.Set("C", "4")
+	cp.Unset("A")
+	if !reflect.DeepEqual(env.Strings(), []string{"A=2", "B=3"}) {
+		t.Errorf("original: %q", env.Strings())
+	}
+	if !reflect.DeepEqual(cp.Strings(), []string{"B=3", "C=4"}) {
+		t.Errorf("clone: %q", cp.Strings())
+	}
+
+	env.Merge(cp)
+	if !reflect.DeepEqual(env.Strings(), []string{"A=2", "B=3", "C=4"}) {
+		t.Errorf("merged: %q", env.Strings())
+	}
+}
+
+func TestEnviron_typed(t *testing.T) {
+	env := nxpipe.Environ{"N=12", "B=true", "D=1m", "BAD=x"}
+	n, err := env.Int("N", 0)
+	if err != nil || n != 12 {
+		t.Errorf("int: %v %v", n, err)
+	}
+	n, err = env.Int("MISSING", 3)
+	if err != nil || n != 3 {
+		t.Errorf("int default: %v %v", n, err)
+	}
+	_, err = env.Int("BAD", 0)
+	if err == nil {
+		t.Errorf("int: expected error")
+	}
+	b, err := env.Bool("B", false)
+	if err != nil || !b {
+		t.Errorf("bool: %v %v", b, err)
+	}
+	d, err := env.Duration("D", 0)
+	if err != nil || d != time.Minute {
+		t.Errorf("duration: %v %v", d, err)
+	}
+}
+
+func TestEnviron_Expand(t *testing.T) {
+	env := nxpipe.Environ{"A=a", "EMPTY="}
+	for _, test := range []struct {
+		in, out string
+	}{
+		{"plain", "plain"},
+		{"$A", "a"},
+		{"${A}b", "ab"},
+		{"$Ab", ""},
+		{"$MISSING", ""},
+		{"${MISSING:-def}", "def"},
+		{"${EMPTY:-def}", "def"},
+		{"${EMPTY-def}", ""},
+		{"${MISSING-$A}", "a"},
+		{"${MISSING:-${A}}", "a"},
+		{"$$A", "$A"},
+		{"s/$/x/", "s/$/x/"},
+		{"$1 ${", "$1 ${"},
+		{"end$", "end$"},
+	} {
+		out := env.Expand(test.in)
+		if out != test.out {
+			t.Errorf("%q: %q (expected %q)", test.in, out, test.out)
+		}
+	}
+}
+
+func TestExec_expand(t *testing.T) {
+	s := nxpipe.NewSession()
+	s.Env = nxpipe.Environ{"GREETING=hello"}
+	r, err := nxpipe.OutputWith(nxpipe.ExecExpand("echo", "$GREETING", "${NAME:-world}"), &nxpipe.OutputOptions{Session: s})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(r.Stdout) != "hello world\n" {
+		t.Errorf("unexpected output: %q", r.Stdout)
+	}
+
+	// Exec passes references through for the program to interpret.
+	r, err = nxpipe.OutputWith(nxpipe.Exec("sh", "-c", `for f in a b; do echo "$GREETING$f"; done`), &nxpipe.OutputOptions{Session: s})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(r.Stdout) != "helloa\nhellob\n" {
+		t.Errorf("unexpected output: %q", r.Stdout)
+	}
+}
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nxpipe-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := nxpipe.NewSession()
+	s.Dir = dir
+	s.Env = nxpipe.Environ{"NAME=out"}
+	p := nxpipe.Script(
+		nxpipe.Line(nxpipe.Exec("echo", "hello"), nxpipe.WriteFile("$NAME.txt", 0644)),
+		nxpipe.Line(nxpipe.Exec("echo", "world"), nxpipe.AppendFile("${NAME}.txt", 0644)),
+	)
+	err = p.RunPipe(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "out.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "hello\nworld\n" {
+		t.Errorf("unexpected content: %q", b)
+	}
+
+	r, err := nxpipe.OutputWith(nxpipe.ReadFile("out.txt"), &nxpipe.OutputOptions{Session: s})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(r.Stdout) != "hello\nworld\n" {
+		t.Errorf("unexpected output: %q", r.Stdout)
+	}
+}
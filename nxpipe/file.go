@@ -0,0 +1,64 @@
+package nxpipe
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Path returns path with variable references expanded using Environ.Expand.
+// Relative paths are interpreted relative to Dir.
+func (s *Session) Path(path string) string {
+	path = s.Environ().Expand(path)
+	if filepath.IsAbs(path) || s.Dir == "" {
+		return path
+	}
+	return filepath.Join(s.Dir, path)
+}
+
+// ReadFile returns a Pipe that writes the contents of the file at path to the
+// Session output, like the shell redirection "<path".  The path is resolved
+// with Session.Path.
+func ReadFile(path string) Pipe {
+	return Func(func(s *Session) error {
+		f, err := os.Open(s.Path(path))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if s.Stdout == nil {
+			return nil
+		}
+		_, err = io.Copy(s.Stdout, f)
+		return err
+	})
+}
+
+// WriteFile returns a Pipe that writes the Session input to the file at path,
+// like the shell redirection ">path".  The path is resolved with Session.Path.
+func WriteFile(path string, perm os.FileMode) Pipe {
+	return writeFile(path, os.O_TRUNC, perm)
+}
+
+// AppendFile is like WriteFile but appends to the file at path, like the shell
+// redirection ">>path".
+func AppendFile(path string, perm os.FileMode) Pipe {
+	return writeFile(path, os.O_APPEND, perm)
+}
+
+func writeFile(path string, flag int, perm os.FileMode) Pipe {
+	return Func(func(s *Session) error {
+		f, err := os.OpenFile(s.Path(path), os.O_WRONLY|os.O_CREATE|flag, perm)
+		if err != nil {
+			return err
+		}
+		if s.Stdin != nil {
+			_, err = io.Copy(f, s.Stdin)
+		}
+		cerr := f.Close()
+		if err != nil {
+			return err
+		}
+		return cerr
+	})
+}
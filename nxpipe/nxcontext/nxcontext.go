@@ -0,0 +1,37 @@
+/*
+Package nxcontext adapts code written against golang.org/x/net/context for use
+with package nxpipe, which uses the standard library context package.
+
+Code written against code.google.com/p/go.net/context, which no longer exists,
+should change its imports to golang.org/x/net/context, the same package at its
+current location, and may then use this package.
+*/
+package nxcontext
+
+import (
+	"context"
+
+	"github.com/bmatsuo/nx/nxpipe"
+	netcontext "golang.org/x/net/context"
+)
+
+// ForkContext converts fn into an nxpipe.ForkContext.  The two context
+// packages define identical interfaces so values pass between them unchanged.
+func ForkContext(fn func(netcontext.Context) (netcontext.Context, netcontext.CancelFunc)) nxpipe.ForkContext {
+	if fn == nil {
+		return nil
+	}
+	return func(c context.Context) (context.Context, context.CancelFunc) {
+		child, cancel := fn(c)
+		return child, context.CancelFunc(cancel)
+	}
+}
+
+// Context returns c as a standard library context.Context so that it may be
+// assigned to nxpipe.Session.Context.
+func Context(c netcontext.Context) context.Context {
+	if c == nil {
+		return nil
+	}
+	return c
+}
@@ -0,0 +1,28 @@
+package nxcontext
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bmatsuo/nx/nxpipe"
+	netcontext "golang.org/x/net/context"
+)
+
+func TestForkContext(t *testing.T) {
+	if ForkContext(nil) != nil {
+		t.Errorf("nil function not preserved")
+	}
+	fork := ForkContext(func(c netcontext.Context) (netcontext.Context, netcontext.CancelFunc) {
+		return netcontext.WithCancel(c)
+	})
+	s := &nxpipe.Session{Context: Context(netcontext.Background())}
+	child, cancel := s.Fork(fork)
+	cancel()
+	<-child.Context.Done()
+	if child.Context.Err() != context.Canceled {
+		t.Errorf("unexpected error: %v", child.Context.Err())
+	}
+	if s.Context.Err() != nil {
+		t.Errorf("parent canceled: %v", s.Context.Err())
+	}
+}
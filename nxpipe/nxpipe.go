@@ -4,10 +4,9 @@ Package nxpipe is similar to gopkg.in/pipe.v2
 package nxpipe
 
 import (
+	"context"
 	"io"
 	"time"
-
-	"code.google.com/p/go.net/context"
 )
 
 // Session is a struct containing the state of a Prog.  A Prog may modify the
@@ -27,14 +26,17 @@ type Session struct {
 
 	// Env is the current environment.  A Prog must use Env instead of
 	// os.Environ for its runtime environment settings.  If Env is empty than
-	// os.Environ should be used.
-	Env []string
+	// os.Environ should be used, as is done by the Environ method.
+	Env Environ
 
 	// Context contains timeout information and arbitrary key-value data.
 	Context context.Context
 
-	// there are probably going to be some unexported things in here.
-	private struct{}
+	// stages records the exit status of Line stages for OutputWith.
+	stages *stageLog
+
+	// procs receives processes started by Exec for ForwardSignal.
+	procs *procGroup
 }
 
 // NewSession returns a Session with an empty context.
@@ -47,15 +49,20 @@ func NewSession() *Session {
 func nop() {}
 
 // Fork allocates and returns a Session initialized with values from the
-// receiver.  If fn is nil the returned Session has the same Context, otherwise
-// the Context is the value returned by fn(s.Context).
+// receiver.  The returned Session always has its own copy of Env so that
+// neither Session observes changes the other makes to it.  If fn is nil the
+// returned Session has the same Context, otherwise the Context is the value
+// returned by fn(s.Context).  The returned CancelFunc is never nil and must be
+// called once the returned Session is no longer in use.
 func (s *Session) Fork(fn ForkContext) (*Session, context.CancelFunc) {
 	cp := new(Session)
 	*cp = *s
+	if cp.Env != nil {
+		cp.Env = cp.Env.Clone()
+	}
 	if fn == nil {
 		return cp, nop
 	}
-	cp.Env = append([]string(nil), cp.Env...)
 	c, cancel := fn(cp.Context)
 	if c == nil {
 		cp.Context = context.Background()
@@ -68,6 +75,9 @@ func (s *Session) Fork(fn ForkContext) (*Session, context.CancelFunc) {
 	return cp, cancel
 }
 
+// ForkContext derives a child Context from the Context of a Session.  Functions
+// written against golang.org/x/net/context can be adapted using
+// package nxpipe/nxcontext.
 type ForkContext func(context.Context) (context.Context, context.CancelFunc)
 
 // ForkWithTimeout returns a ForkContext which adds a d timeout to the supplied
@@ -78,7 +88,7 @@ func ForkWithTimeout(d time.Duration) ForkContext {
 	}
 }
 
-// ForkWithTimeout returns a ForkContext which adds a t deadline to the supplied
+// ForkWithDeadline returns a ForkContext which adds a t deadline to the supplied
 // context.
 func ForkWithDeadline(t time.Time) ForkContext {
 	return func(c context.Context) (context.Context, context.CancelFunc) {
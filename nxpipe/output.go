@@ -0,0 +1,204 @@
+package nxpipe
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+)
+
+// OutputOptions configures the behavior of OutputWith.
+type OutputOptions struct {
+	// Session is forked to run the Pipe, allowing Dir, Env, Stdin and Context
+	// to be configured by the caller.  If Session is nil a new Session is used.
+	// If its Context is nil context.Background() is used.
+	Session *Session
+
+	// MaxSize is the maximum number of bytes captured from each output
+	// stream.  When a stream exceeds MaxSize the leading and trailing bytes
+	// of the stream are kept and the middle is discarded.  If MaxSize is not
+	// positive output is not limited.
+	MaxSize int
+
+	// Combined causes Stdout and Stderr writes to be interleaved in
+	// Result.Stdout like CombinedOutput.
+	Combined bool
+}
+
+// Result is the output captured by OutputWith.
+type Result struct {
+	// Stdout and Stderr contain captured output.  If OutputOptions.Combined
+	// was set Stderr is always empty.
+	Stdout []byte
+	Stderr []byte
+
+	// StdoutSize and StderrSize are the total number of bytes written to
+	// each stream.  They are larger than the length of the corresponding
+	// captured output if MaxSize was exceeded.
+	StdoutSize int64
+	StderrSize int64
+
+	// Stages contains the Status of each stage in the outermost Line of the
+	// Pipe.  If the Pipe was not a Line Stages contains a single Status.
+	Stages []Status
+}
+
+// Truncated returns true if any captured output was discarded because it
+// exceeded OutputOptions.MaxSize.
+func (r *Result) Truncated() bool {
+	return int64(len(r.Stdout)) < r.StdoutSize || int64(len(r.Stderr)) < r.StderrSize
+}
+
+// Status is the exit status of a single stage in a Line.
+type Status struct {
+	// Stage is the index of the Pipe in the arguments given to Line.
+	Stage int
+
+	// Err is the error returned by the stage.
+	Err error
+}
+
+// OutputWith is like Output but captures Stdout and Stderr separately, limits
+// the amount of output retained and reports the status of each pipeline
+// stage.  The returned error is the error returned by p.
+func OutputWith(p Pipe, opt *OutputOptions) (*Result, error) {
+	if opt == nil {
+		opt = &OutputOptions{}
+	}
+	var s *Session
+	if opt.Session == nil {
+		s = NewSession()
+	} else {
+		s, _ = opt.Session.Fork(nil)
+		if s.Context == nil {
+			s.Context = context.Background()
+		}
+	}
+	stdout := newCapBuffer(opt.MaxSize)
+	stderr := stdout
+	if !opt.Combined {
+		stderr = newCapBuffer(opt.MaxSize)
+	}
+	s.Stdout = stdout
+	s.Stderr = stderr
+	s.stages = new(stageLog)
+
+	err := p.RunPipe(s)
+
+	r := &Result{
+		Stdout:     stdout.Bytes(),
+		StdoutSize: stdout.Size(),
+		Stages:     s.stages.statuses(),
+	}
+	if !opt.Combined {
+		r.Stderr = stderr.Bytes()
+		r.StderrSize = stderr.Size()
+	}
+	if len(r.Stages) == 0 {
+		r.Stages = []Status{{Stage: 0, Err: err}}
+	}
+	return r, err
+}
+
+// stageLog collects the Status of each stage in a Line.
+type stageLog struct {
+	mut    sync.Mutex
+	stages []Status
+}
+
+func (l *stageLog) init(n int) {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	l.stages = make([]Status, n)
+	for i := range l.stages {
+		l.stages[i].Stage = i
+	}
+}
+
+func (l *stageLog) exit(i int, err error) {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	l.stages[i].Err = err
+}
+
+func (l *stageLog) statuses() []Status {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	return append([]Status(nil), l.stages...)
+}
+
+// capBuffer is an io.Writer that retains at most max bytes, keeping the
+// beginning and the end of what is written to it.
+type capBuffer struct {
+	mut  sync.Mutex
+	max  int
+	size int64
+	head bytes.Buffer
+	tail []byte // ring buffer
+	off  int    // next write position in tail
+	full bool   // tail has wrapped
+}
+
+var _ io.Writer = new(capBuffer)
+
+func newCapBuffer(max int) *capBuffer {
+	return &capBuffer{max: max}
+}
+
+func (b *capBuffer) Write(p []byte) (int, error) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	n := len(p)
+	b.size += int64(n)
+	if b.max <= 0 {
+		b.head.Write(p)
+		return n, nil
+	}
+	nhead := b.max - b.max/2
+	if b.head.Len() < nhead {
+		k := nhead - b.head.Len()
+		if k > len(p) {
+			k = len(p)
+		}
+		b.head.Write(p[:k])
+		p = p[k:]
+	}
+	ntail := b.max / 2
+	if ntail == 0 {
+		return n, nil
+	}
+	if b.tail == nil && len(p) > 0 {
+		b.tail = make([]byte, ntail)
+	}
+	if len(p) > ntail {
+		p = p[len(p)-ntail:]
+	}
+	for len(p) > 0 {
+		k := copy(b.tail[b.off:], p)
+		p = p[k:]
+		b.off += k
+		if b.off == ntail {
+			b.off = 0
+			b.full = true
+		}
+	}
+	return n, nil
+}
+
+// Bytes returns the retained bytes.
+func (b *capBuffer) Bytes() []byte {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	out := append([]byte(nil), b.head.Bytes()...)
+	if b.full {
+		out = append(out, b.tail[b.off:]...)
+	}
+	return append(out, b.tail[:b.off]...)
+}
+
+// Size returns the total number of bytes written.
+func (b *capBuffer) Size() int64 {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	return b.size
+}
@@ -0,0 +1,155 @@
+package nxpipe
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestCapBuffer(t *testing.T) {
+	for i, test := range []struct {
+		max    int
+		writes []string
+		out    string
+	}{
+		{0, []string{"hello", " ", "world"}, "hello world"},
+		{20, []string{"hello", " ", "world"}, "hello world"},
+		{11, []string{"hello", " ", "world"}, "hello world"},
+		{4, []string{"hello", " ", "world"}, "held"},
+		{5, []string{"hello world"}, "helld"},
+		{6, []string{"a", "b", "c", "d", "e", "f", "g", "h"}, "abcfgh"},
+		{1, []string{"hello"}, "h"},
+	} {
+		b := newCapBuffer(test.max)
+		var size int64
+		for _, w := range test.writes {
+			b.Write([]byte(w))
+			size += int64(len(w))
+		}
+		if string(b.Bytes()) != test.out {
+			t.Errorf("test %d: %q (expected %q)", i, b.Bytes(), test.out)
+		}
+		if b.Size() != size {
+			t.Errorf("test %d: size %d (expected %d)", i, b.Size(), size)
+		}
+	}
+}
+
+func TestOutputWith(t *testing.T) {
+	p := Func(func(s *Session) error {
+		io.WriteString(s.Stdout, "out")
+		io.WriteString(s.Stderr, "err")
+		return nil
+	})
+	r, err := OutputWith(p, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(r.Stdout) != "out" {
+		t.Errorf("stdout: %q", r.Stdout)
+	}
+	if string(r.Stderr) != "err" {
+		t.Errorf("stderr: %q", r.Stderr)
+	}
+	if len(r.Stages) != 1 || r.Stages[0].Err != nil {
+		t.Errorf("stages: %v", r.Stages)
+	}
+
+	r, err = OutputWith(p, &OutputOptions{Combined: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(r.Stdout) != "outerr" {
+		t.Errorf("combined: %q", r.Stdout)
+	}
+	if len(r.Stderr) != 0 {
+		t.Errorf("combined stderr: %q", r.Stderr)
+	}
+}
+
+func TestOutputWith_maxSize(t *testing.T) {
+	p := Func(func(s *Session) error {
+		_, err := io.WriteString(s.Stdout, strings.Repeat("x", 100)+"end")
+		return err
+	})
+	r, err := OutputWith(p, &OutputOptions{MaxSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(r.Stdout) != "xxxxxxxend" {
+		t.Errorf("stdout: %q", r.Stdout)
+	}
+	if r.StdoutSize != 103 {
+		t.Errorf("size: %d", r.StdoutSize)
+	}
+	if !r.Truncated() {
+		t.Errorf("not truncated")
+	}
+}
+
+func TestOutputWith_session(t *testing.T) {
+	s := NewSession()
+	s.Dir = "/some/dir"
+	s.Stdin = bytes.NewBufferString("input")
+	p := Func(func(s *Session) error {
+		in, err := ioutil.ReadAll(s.Stdin)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(s.Stdout, "%s %s", s.Dir, in)
+		return err
+	})
+	r, err := OutputWith(p, &OutputOptions{Session: s})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(r.Stdout) != "/some/dir input" {
+		t.Errorf("stdout: %q", r.Stdout)
+	}
+	if s.Stdout != nil {
+		t.Errorf("session was modified")
+	}
+}
+
+func TestOutputWith_nilContext(t *testing.T) {
+	s := &Session{}
+	r, err := OutputWith(Exec("echo", "hello"), &OutputOptions{Session: s})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(r.Stdout) != "hello\n" {
+		t.Errorf("stdout: %q", r.Stdout)
+	}
+	if s.Context != nil {
+		t.Errorf("session was modified")
+	}
+}
+
+func TestOutputWith_stages(t *testing.T) {
+	errFirst := fmt.Errorf("first")
+	p := Line(
+		Func(func(s *Session) error {
+			return errFirst
+		}),
+		Func(func(s *Session) error {
+			_, err := io.Copy(s.Stdout, s.Stdin)
+			return err
+		}),
+	)
+	r, err := OutputWith(p, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Stages) != 2 {
+		t.Fatalf("stages: %v", r.Stages)
+	}
+	if r.Stages[0].Stage != 0 || r.Stages[0].Err != errFirst {
+		t.Errorf("stage 0: %v", r.Stages[0])
+	}
+	if r.Stages[1].Stage != 1 || r.Stages[1].Err != nil {
+		t.Errorf("stage 1: %v", r.Stages[1])
+	}
+}
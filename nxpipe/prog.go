@@ -2,6 +2,7 @@ package nxpipe
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -15,6 +16,13 @@ func Run(p Pipe) error {
 	return p.RunPipe(NewSession())
 }
 
+// RunContext is like Run but the Session uses c as its Context.
+func RunContext(c context.Context, p Pipe) error {
+	s := NewSession()
+	s.Context = c
+	return p.RunPipe(s)
+}
+
 // CombinedOutput is like Run but Stdout and Stderr writes are interleaved in a
 // buffer and returned.
 func CombinedOutput(p Pipe) ([]byte, error) {
@@ -35,6 +43,16 @@ func Output(p Pipe) ([]byte, error) {
 	return buf.Bytes(), err
 }
 
+// OutputContext is like Output but the Session uses c as its Context.
+func OutputContext(c context.Context, p Pipe) ([]byte, error) {
+	var buf bytes.Buffer
+	s := NewSession()
+	s.Context = c
+	s.Stdout = &buf
+	err := p.RunPipe(s)
+	return buf.Bytes(), err
+}
+
 // Pipe abstracts the notion of a unix command.
 type Pipe interface {
 	RunPipe(s *Session) error
@@ -52,7 +70,8 @@ func (fn Func) RunPipe(s *Session) error {
 func Script(p ...Pipe) Pipe {
 	source := Source(p...)
 	return Func(func(s *Session) error {
-		child, _ := s.Fork(nil)
+		child, cancel := s.Fork(nil)
+		defer cancel()
 		return source.RunPipe(child)
 	})
 }
@@ -88,13 +107,20 @@ func Line(p ...Pipe) Pipe {
 		if s.Stdin != nil {
 			stdin = ioutil.NopCloser(s.Stdin)
 		}
+		// only the outermost Line records the status of its stages.
+		stages := s.stages
+		var wait sync.WaitGroup
+		if stages != nil {
+			stages.init(len(p))
+		}
 		errc := make(chan error)
 		for i := range p {
 			i := i
 			prog := p[i]
 			needsClose := make([]io.Closer, 0, 2)
 			last := i == len(p)-1
-			child, _ := s.Fork(nil)
+			child, cancel := s.Fork(nil)
+			child.stages = nil
 			child.Stdin = stdin
 			if i > 0 {
 				needsClose = append(needsClose, stdin)
@@ -106,12 +132,18 @@ func Line(p ...Pipe) Pipe {
 				needsClose = append(needsClose, w)
 				stdin, child.Stdout = r, w
 			}
+			wait.Add(1)
 			go func() {
+				defer wait.Done()
 				err := prog.RunPipe(child)
+				cancel()
 
 				for i := range needsClose {
 					needsClose[i].Close()
 				}
+				if stages != nil {
+					stages.exit(i, err)
+				}
 				if last {
 					if err != nil {
 						errc <- err
@@ -120,7 +152,12 @@ func Line(p ...Pipe) Pipe {
 				}
 			}()
 		}
-		return <-errc
+		err := <-errc
+		if stages != nil {
+			// statuses are only complete once every stage has exited.
+			wait.Wait()
+		}
+		return err
 	})
 }
 
@@ -128,8 +165,27 @@ func Line(p ...Pipe) Pipe {
 // from the Session.  If the Session is cancelled any spawned process will be
 // killed.
 func Exec(name string, args ...string) Pipe {
+	return execPipe(name, args, false)
+}
+
+// ExecExpand is like Exec but variable references in name and args are
+// expanded using Environ.Expand before the program is executed.
+func ExecExpand(name string, args ...string) Pipe {
+	return execPipe(name, args, true)
+}
+
+func execPipe(name string, args []string, expand bool) Pipe {
 	return Func(func(s *Session) error {
-		c := exec.Command(name, args...)
+		cmd, cargs := name, args
+		if expand {
+			env := s.Environ()
+			cmd = env.Expand(name)
+			cargs = make([]string, len(args))
+			for i, arg := range args {
+				cargs[i] = env.Expand(arg)
+			}
+		}
+		c := exec.Command(cmd, cargs...)
 		c.Dir = s.Dir
 		c.Env = s.Env
 		var numio int
@@ -159,11 +215,19 @@ func Exec(name string, args ...string) Pipe {
 			}
 		}
 
+		if s.procs != nil {
+			setProcessGroup(c)
+		}
 		err = c.Start()
 		if err != nil {
 			return err
 		}
-		cerr := make(chan error, numio)
+		if s.procs != nil {
+			s.procs.add(c.Process)
+			defer s.procs.remove(c.Process)
+		}
+		// stdin may report an error for both the copy and the close.
+		cerr := make(chan error, numio+1)
 		wait := new(sync.WaitGroup)
 		wait.Add(numio)
 		go func() {
@@ -208,24 +272,46 @@ func Exec(name string, args ...string) Pipe {
 				c.Wait()
 				return err
 			}
-			return c.Wait()
 		case <-s.Context.Done():
 			c.Process.Kill()
 			c.Wait() // closes files
 			return s.Context.Err()
 		}
+
+		// the process may outlive its output streams (or have none at all) so
+		// cancellation must be observed while waiting for it too.
+		cwait := make(chan error, 1)
+		go func() {
+			cwait <- c.Wait()
+		}()
+		select {
+		case err := <-cwait:
+			return err
+		case <-s.Context.Done():
+			c.Process.Kill()
+			<-cwait
+			return s.Context.Err()
+		}
 	})
 }
 
 // withContext returns a Pipe that uses fork to create a child context with
-// which to run p.
+// which to run p.  The child context is released when p returns.  Aside from
+// its Context the Session given to p is not forked so, like Source, p may
+// modify it.
 func withContext(fork ForkContext, p Pipe) Pipe {
 	return Func(func(s *Session) error {
 		c := s.Context
-		s.Context, _ = fork(s.Context)
-		err := p.RunPipe(s)
-		s.Context = c
-		return err
+		child, cancel := fork(c)
+		if child == nil {
+			child = context.Background()
+		}
+		if cancel != nil {
+			defer cancel()
+		}
+		s.Context = child
+		defer func() { s.Context = c }()
+		return p.RunPipe(s)
 	})
 }
 
@@ -0,0 +1,174 @@
+package nxpipe_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bmatsuo/nx/nxpipe"
+)
+
+func TestSessionFork_env(t *testing.T) {
+	s := nxpipe.NewSession()
+	s.Env = make([]string, 1, 10)
+	s.Env[0] = "A=1"
+
+	for _, fn := range []nxpipe.ForkContext{nil, nxpipe.ForkWithTimeout(time.Hour)} {
+		child, cancel := s.Fork(fn)
+		child.Env[0] = "A=2"
+		child.Env = append(child.Env, "B=3")
+		cancel()
+		if len(s.Env) != 1 || s.Env[0] != "A=1" {
+			t.Errorf("parent env modified: %q", s.Env)
+		}
+		if s.Env[:2][1] != "" {
+			t.Errorf("parent env backing array modified: %q", s.Env[:2])
+		}
+	}
+}
+
+func TestSessionFork_cancel(t *testing.T) {
+	s := nxpipe.NewSession()
+	child, cancel := s.Fork(nil)
+	if cancel == nil {
+		t.Fatalf("nil cancel")
+	}
+	cancel()
+	if child.Context != s.Context {
+		t.Errorf("context changed")
+	}
+
+	child, cancel = s.Fork(func(c context.Context) (context.Context, context.CancelFunc) {
+		return nil, nil
+	})
+	if cancel == nil {
+		t.Fatalf("nil cancel")
+	}
+	cancel()
+	if child.Context == nil {
+		t.Errorf("nil context")
+	}
+}
+
+func TestWithTimeout_release(t *testing.T) {
+	var c context.Context
+	p := nxpipe.WithTimeout(time.Hour, nxpipe.Func(func(s *nxpipe.Session) error {
+		c = s.Context
+		return nil
+	}))
+	s := nxpipe.NewSession()
+	parent := s.Context
+	err := p.RunPipe(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Err() != context.Canceled {
+		t.Errorf("context was not released: %v", c.Err())
+	}
+	if s.Context != parent {
+		t.Errorf("context was not restored")
+	}
+}
+
+func TestWithTimeout_exec(t *testing.T) {
+	start := time.Now()
+	p := nxpipe.WithTimeout(50*time.Millisecond, nxpipe.Exec("sleep", "10"))
+	err := nxpipe.Run(p)
+	if err != context.DeadlineExceeded {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("process was not killed")
+	}
+}
+
+func TestWithDeadline_release(t *testing.T) {
+	var c context.Context
+	p := nxpipe.WithDeadline(time.Now().Add(time.Hour), nxpipe.Func(func(s *nxpipe.Session) error {
+		c = s.Context
+		return fmt.Errorf("failed")
+	}))
+	err := nxpipe.Run(p)
+	if err == nil {
+		t.Errorf("expected error")
+	}
+	if c.Err() != context.Canceled {
+		t.Errorf("context was not released: %v", c.Err())
+	}
+}
+
+func TestScript_isolated(t *testing.T) {
+	setenv := nxpipe.Func(func(s *nxpipe.Session) error {
+		s.Env = append(s.Env, "X=1")
+		s.Dir = "/changed"
+		return nil
+	})
+	s := nxpipe.NewSession()
+	err := nxpipe.Script(setenv).RunPipe(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Env) != 0 || s.Dir != "" {
+		t.Errorf("script modified session: %q %q", s.Env, s.Dir)
+	}
+	err = nxpipe.Source(setenv).RunPipe(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Env) != 1 || s.Dir != "/changed" {
+		t.Errorf("source did not modify session: %q %q", s.Env, s.Dir)
+	}
+}
+
+func TestLine_concurrent(t *testing.T) {
+	upper := nxpipe.Func(func(s *nxpipe.Session) error {
+		s.Env = append(s.Env, "STAGE=upper")
+		b, err := ioutil.ReadAll(s.Stdin)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(s.Stdout, strings.ToUpper(string(b)))
+		return err
+	})
+	p := nxpipe.Line(
+		nxpipe.Func(func(s *nxpipe.Session) error {
+			s.Env[0] = "STAGE=source"
+			_, err := io.WriteString(s.Stdout, "hello")
+			return err
+		}),
+		upper,
+		nxpipe.WithTimeout(time.Minute, upper),
+	)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s, cancel := nxpipe.NewSession().Fork(nxpipe.ForkWithTimeout(time.Minute))
+			defer cancel()
+			s.Env = []string{"STAGE=none"}
+			r, err := nxpipe.OutputWith(p, &nxpipe.OutputOptions{Session: s})
+			if err == nil && string(r.Stdout) != "HELLO" {
+				err = fmt.Errorf("unexpected output: %q", r.Stdout)
+			}
+			if err == nil && s.Env[0] != "STAGE=none" {
+				err = fmt.Errorf("env modified: %q", s.Env)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package nxpipe
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// defaultSignals are handled when RunWithSignals or ForwardSignal is not
+// given any signals explicitly.
+var defaultSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// SignalError is returned by RunWithSignals when a Pipe was cancelled because
+// the process received a signal.
+type SignalError struct {
+	Signal os.Signal
+}
+
+func (err *SignalError) Error() string {
+	return "received signal: " + err.Signal.String()
+}
+
+// RunWithSignals is like Run but the Session Context is cancelled when the
+// process receives any of sig, killing any processes started by Exec.  If sig
+// is empty os.Interrupt and syscall.SIGTERM are handled.  If p fails after a
+// signal is received the returned error is a *SignalError.
+func RunWithSignals(p Pipe, sig ...os.Signal) error {
+	if len(sig) == 0 {
+		sig = defaultSignals
+	}
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := NewSession()
+	s.Context = c
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, sig...)
+	defer signal.Stop(sigc)
+	received := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case x := <-sigc:
+			received <- x
+			cancel()
+		case <-done:
+		}
+	}()
+
+	err := p.RunPipe(s)
+	if err != nil {
+		select {
+		case x := <-received:
+			return &SignalError{x}
+		default:
+		}
+	}
+	return err
+}
+
+// ForwardSignal returns a Pipe that runs p, relaying any of sig received by
+// the process to the processes p starts with Exec.  The Session is not
+// cancelled, so a signal like syscall.SIGHUP can be used to make a long
+// running child reopen its log files.  If sig is empty os.Interrupt and
+// syscall.SIGTERM are forwarded.
+//
+// Where supported, processes started by p are placed in their own process
+// group and a forwarded signal is delivered to the entire group.  As a
+// consequence signals generated by the terminal, such as the SIGINT sent by
+// Ctrl-C, no longer reach those processes directly; they receive only the
+// signals in sig.  Processes started by Exec outside of ForwardSignal remain
+// in the process group of the calling process.
+func ForwardSignal(p Pipe, sig ...os.Signal) Pipe {
+	if len(sig) == 0 {
+		sig = defaultSignals
+	}
+	return Func(func(s *Session) error {
+		child, cancel := s.Fork(nil)
+		defer cancel()
+		if child.procs == nil {
+			child.procs = new(procGroup)
+		}
+
+		sigc := make(chan os.Signal, 1)
+		signal.Notify(sigc, sig...)
+		defer signal.Stop(sigc)
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			for {
+				select {
+				case x := <-sigc:
+					child.procs.signal(x)
+				case <-done:
+					return
+				}
+			}
+		}()
+
+		return p.RunPipe(child)
+	})
+}
+
+// procGroup tracks the running processes started by Exec so they can be
+// signalled.
+type procGroup struct {
+	mut   sync.Mutex
+	procs map[*os.Process]struct{}
+}
+
+func (g *procGroup) add(p *os.Process) {
+	g.mut.Lock()
+	defer g.mut.Unlock()
+	if g.procs == nil {
+		g.procs = make(map[*os.Process]struct{})
+	}
+	g.procs[p] = struct{}{}
+}
+
+func (g *procGroup) remove(p *os.Process) {
+	g.mut.Lock()
+	defer g.mut.Unlock()
+	delete(g.procs, p)
+}
+
+func (g *procGroup) signal(sig os.Signal) {
+	g.mut.Lock()
+	defer g.mut.Unlock()
+	for p := range g.procs {
+		signalProcessGroup(p, sig)
+	}
+}
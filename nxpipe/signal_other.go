@@ -0,0 +1,15 @@
+//go:build !unix
+// +build !unix
+
+package nxpipe
+
+import (
+	"os"
+	"os/exec"
+)
+
+func setProcessGroup(c *exec.Cmd) {}
+
+func signalProcessGroup(p *os.Process, sig os.Signal) error {
+	return p.Signal(sig)
+}
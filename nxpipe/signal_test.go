@@ -0,0 +1,90 @@
+//go:build unix
+// +build unix
+
+package nxpipe_test
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/bmatsuo/nx/nxpipe"
+)
+
+func TestRunWithSignals(t *testing.T) {
+	p := nxpipe.Func(func(s *nxpipe.Session) error {
+		syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+		select {
+		case <-s.Context.Done():
+			return s.Context.Err()
+		case <-time.After(5 * time.Second):
+			return nil
+		}
+	})
+	err := nxpipe.RunWithSignals(p, syscall.SIGUSR1)
+	serr, ok := err.(*nxpipe.SignalError)
+	if !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if serr.Signal != syscall.SIGUSR1 {
+		t.Errorf("unexpected signal: %v", serr.Signal)
+	}
+}
+
+func TestForwardSignal(t *testing.T) {
+	script := `trap 'echo hup; exit 0' HUP; echo ready; while :; do sleep 0.05; done`
+	sh := nxpipe.Exec("sh", "-c", script)
+	p := nxpipe.ForwardSignal(nxpipe.Line(
+		sh,
+		nxpipe.Func(func(s *nxpipe.Session) error {
+			buf := make([]byte, len("ready\n"))
+			_, err := s.Stdin.Read(buf)
+			if err != nil {
+				return err
+			}
+			syscall.Kill(syscall.Getpid(), syscall.SIGHUP)
+			n, err := s.Stdin.Read(buf)
+			if err != nil {
+				return err
+			}
+			_, err = s.Stdout.Write(buf[:n])
+			return err
+		}),
+	), syscall.SIGHUP)
+	out, err := nxpipe.Output(nxpipe.WithTimeout(10*time.Second, p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "hup\n" {
+		t.Errorf("unexpected output: %q", out)
+	}
+}
+
+func TestExecProcessGroup(t *testing.T) {
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("/proc is not available")
+	}
+	pgid := func(p nxpipe.Pipe) int {
+		out, err := nxpipe.Output(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the process group is the fifth field, following the command name.
+		fields := strings.Fields(string(out[strings.LastIndexByte(string(out), ')')+1:]))
+		id, err := strconv.Atoi(fields[2])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	stat := nxpipe.Exec("cat", "/proc/self/stat")
+	if id := pgid(stat); id != syscall.Getpgrp() {
+		t.Errorf("Exec started process group %d", id)
+	}
+	if id := pgid(nxpipe.ForwardSignal(stat, syscall.SIGHUP)); id == syscall.Getpgrp() {
+		t.Errorf("ForwardSignal did not start a process group")
+	}
+}
@@ -0,0 +1,27 @@
+//go:build unix
+// +build unix
+
+package nxpipe
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup causes c to be started as the leader of a new process
+// group.
+func setProcessGroup(c *exec.Cmd) {
+	if c.SysProcAttr == nil {
+		c.SysProcAttr = new(syscall.SysProcAttr)
+	}
+	c.SysProcAttr.Setpgid = true
+}
+
+// signalProcessGroup delivers sig to the process group led by p.
+func signalProcessGroup(p *os.Process, sig os.Signal) error {
+	if s, ok := sig.(syscall.Signal); ok {
+		return syscall.Kill(-p.Pid, s)
+	}
+	return p.Signal(sig)
+}
@@ -3,6 +3,7 @@ package nxregexp
 import (
 	"regexp"
 
+	"github.com/bmatsuo/nx"
 	"gopkg.in/pipe.v2"
 )
 
@@ -26,20 +27,54 @@ func MustCompile(pat string) *Regexp {
 	return r
 }
 
-func (r *Regexp) Match() pipe.Pipe {
+// Match returns a pipe.Pipe that emits lines read from stdin that match r.
+// Lines are split according to opts, see nx.LineOptions.
+func (r *Regexp) Match(opts ...nx.LineOptions) pipe.Pipe {
 	return func(s *pipe.State) error {
-		return pipe.Filter(r.Regexp.Match)(s)
+		return nx.Filter(r.Regexp.Match, opts...)(s)
 	}
 }
 
-func (r *Regexp) ReplaceAll(repl []byte) pipe.Pipe {
-	replr := &Replacer{r, repl}
+// ReplaceAll returns a pipe.Pipe that emits lines read from stdin with all
+// matches of r replaced by repl.  Lines are split according to opts, see
+// nx.LineOptions.
+func (r *Regexp) ReplaceAll(repl []byte, opts ...nx.LineOptions) pipe.Pipe {
+	replr := &Replacer{Regexp: r, Repl: repl}
+	if len(opts) > 0 {
+		replr.Lines = opts[len(opts)-1]
+	}
 	return replr.All
 }
 
+// Grep returns a pipe.Pipe that emits the lines read from stdin that match r
+// as described by opts, see nx.Grep.
+func (r *Regexp) Grep(opts *nx.GrepOptions) pipe.Pipe {
+	return nx.Grep(r.Regexp, opts)
+}
+
+// MatchRecord returns true if the text of rec matches r.  It may be used as
+// the pattern of an nx.AwkRule.
+func (r *Regexp) MatchRecord(rec *nx.Record) bool {
+	return r.Regexp.MatchString(rec.Text)
+}
+
+// Find returns a pipe.Pipe that emits the paths beneath root that match r and
+// satisfy opts, see nx.Find.
+func (r *Regexp) Find(root string, opts *nx.FindOptions) pipe.Pipe {
+	var opt nx.FindOptions
+	if opts != nil {
+		opt = *opts
+	}
+	opt.Regexp = r.Regexp
+	return nx.Find(root, &opt)
+}
+
 type Replacer struct {
 	*Regexp
 	Repl []byte
+
+	// Lines determines how input is split into lines.
+	Lines nx.LineOptions
 }
 
 // TODO: Replacer.First
@@ -48,5 +83,5 @@ func (r *Replacer) All(s *pipe.State) error {
 	p := func(bs []byte) []byte {
 		return r.Regexp.Regexp.ReplaceAll(bs, r.Repl)
 	}
-	return pipe.Replace(p)(s)
+	return nx.Replace(p, r.Lines)(s)
 }
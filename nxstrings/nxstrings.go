@@ -2,32 +2,32 @@ package nxstrings
 
 import (
 	"bufio"
-	"fmt"
 	"io"
 	"strings"
 
+	"github.com/bmatsuo/nx"
 	"github.com/bmatsuo/nx/nxbytes"
 	"gopkg.in/pipe.v2"
 )
 
-func Contains(substr string) pipe.Pipe {
-	return nxbytes.Contains([]byte(substr))
+func Contains(substr string, opts ...nx.LineOptions) pipe.Pipe {
+	return nxbytes.Contains([]byte(substr), opts...)
 }
 
-func HasSuffix(suffix string) pipe.Pipe {
-	return nxbytes.HasSuffix([]byte(suffix))
+func HasSuffix(suffix string, opts ...nx.LineOptions) pipe.Pipe {
+	return nxbytes.HasSuffix([]byte(suffix), opts...)
 }
 
-func HasPrefix(prefix string) pipe.Pipe {
-	return nxbytes.HasPrefix([]byte(prefix))
+func HasPrefix(prefix string, opts ...nx.LineOptions) pipe.Pipe {
+	return nxbytes.HasPrefix([]byte(prefix), opts...)
 }
 
-func TrimSuffix(suffix string) pipe.Pipe {
-	return nxbytes.TrimSuffix([]byte(suffix))
+func TrimSuffix(suffix string, opts ...nx.LineOptions) pipe.Pipe {
+	return nxbytes.TrimSuffix([]byte(suffix), opts...)
 }
 
-func TrimPrefix(prefix string) pipe.Pipe {
-	return nxbytes.TrimPrefix([]byte(prefix))
+func TrimPrefix(prefix string, opts ...nx.LineOptions) pipe.Pipe {
+	return nxbytes.TrimPrefix([]byte(prefix), opts...)
 }
 
 func Repeat(s string, count int) pipe.Pipe {
@@ -50,19 +50,6 @@ func Repeat(s string, count int) pipe.Pipe {
 	}
 }
 
-func ReplaceAll(sub, repl string) pipe.Pipe {
-	return func(p *pipe.State) error {
-		s := bufio.NewScanner(p.Stdin)
-		for {
-			if !s.Scan() {
-				return s.Err()
-			}
-			ln := s.Text()
-			ln = strings.Replace(ln, sub, repl, -1)
-			_, err := fmt.Fprintln(p.Stdout, ln)
-			if err != nil {
-				return err
-			}
-		}
-	}
+func ReplaceAll(sub, repl string, opts ...nx.LineOptions) pipe.Pipe {
+	return nxbytes.ReplaceAll([]byte(sub), []byte(repl), opts...)
 }
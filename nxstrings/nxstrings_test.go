@@ -0,0 +1,28 @@
+package nxstrings_test
+
+import (
+	"testing"
+
+	"github.com/bmatsuo/nx"
+	"github.com/bmatsuo/nx/nxstrings"
+	"gopkg.in/pipe.v2"
+)
+
+func TestPipes(t *testing.T) {
+	for i, test := range []struct {
+		p       pipe.Pipe
+		in, out string
+	}{
+		{nxstrings.HasSuffix("a"), "ab\nba\nc\n", "ba\n"},
+		{nxstrings.ReplaceAll("a", "x"), "aba\nca\n", "xbx\ncx\n"},
+		{nxstrings.ReplaceAll("a", "x"), "aba\nca", "xbx\ncx"},
+		{nxstrings.ReplaceAll("a", "x", nx.NULLines), "aba\x00ca\x00", "xbx\x00cx\x00"},
+	} {
+		out, err := pipe.Output(pipe.Line(pipe.Print(test.in), test.p))
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+		} else if string(out) != test.out {
+			t.Errorf("test %d: output %q (expected %q)", i, out, test.out)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package nx
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"syscall"
+
+	"gopkg.in/pipe.v2"
+)
+
+// ErrUnequalLength is returned by PasteWith in PasteStrict mode when inputs
+// contain different numbers of lines.
+var ErrUnequalLength = errors.New("nx: inputs have unequal numbers of lines")
+
+// PasteMode determines how pasting handles inputs with unequal numbers of
+// lines.
+type PasteMode int
+
+// Available PasteMode values.
+const (
+	// PasteLongest continues until every input ends, filling the columns of
+	// inputs that have ended, like paste(1).
+	PasteLongest PasteMode = iota
+	// PasteShortest stops when any input ends.
+	PasteShortest
+	// PasteStrict fails with ErrUnequalLength if any input ends before the
+	// others.
+	PasteStrict
+)
+
+// PasteOptions configures PasteWith.
+type PasteOptions struct {
+	// Delim separates columns.  If Delim is empty a tab is used.
+	Delim string
+
+	// Mode determines how inputs of unequal lengths are handled.
+	Mode PasteMode
+
+	// Fill is emitted in the columns of inputs that have ended in
+	// PasteLongest mode.
+	Fill string
+
+	// Lines determines how input is split into lines.  Output lines are
+	// terminated by Lines.Delim, or '\n' if it is empty.
+	Lines LineOptions
+}
+
+// Paste returns a pipe.Pipe that emits lines joining corresponding lines of
+// stdin and the output of each source, separated by delim, like paste(1).
+// Stdin forms the first column.  Sources are run concurrently with no input;
+// use pipe.ReadFile to paste a file.  Columns of inputs that have ended are
+// empty.
+func Paste(delim string, sources ...pipe.Pipe) pipe.Pipe {
+	return PasteWith(&PasteOptions{Delim: delim}, sources...)
+}
+
+// Zip returns a pipe.Pipe that emits lines joining corresponding lines of
+// stdin and the output of each source, separated by tabs, until any input
+// ends.  Sources are run as with Paste.
+func Zip(sources ...pipe.Pipe) pipe.Pipe {
+	return PasteWith(&PasteOptions{Mode: PasteShortest}, sources...)
+}
+
+// PasteWith returns a pipe.Pipe that pastes stdin and the output of sources
+// as described by opts, see Paste.  A nil opts is equivalent to a zero
+// PasteOptions.  Errors from sources are returned after output is written.
+// In PasteShortest mode sources still writing when pasting stops fail with a
+// broken pipe, and those errors are ignored.
+func PasteWith(opts *PasteOptions, sources ...pipe.Pipe) pipe.Pipe {
+	if opts == nil {
+		opts = &PasteOptions{}
+	}
+	opt := *opts
+	if opt.Delim == "" {
+		opt.Delim = "\t"
+	}
+	return groupTaskFunc(func(p *pipe.State, g *stateGroup) error {
+		var wg sync.WaitGroup
+		errs := make([]error, len(sources))
+		readers := make([]*lineReader, 1+len(sources))
+		readers[0] = newLineReader(p.Stdin, opt.Lines)
+		closers := make([]*io.PipeReader, len(sources))
+		for i, src := range sources {
+			pr, pw := io.Pipe()
+			closers[i] = pr
+			readers[i+1] = newLineReader(pr, opt.Lines)
+			s := newSubState(p, nil, pw, p.Stderr)
+			wg.Add(1)
+			go func(i int, src pipe.Pipe) {
+				defer wg.Done()
+				errs[i] = g.run(src, s)
+				pw.CloseWithError(errs[i])
+			}(i, src)
+		}
+
+		err := paste(p.Stdout, readers, &opt)
+		for _, pr := range closers {
+			pr.Close()
+		}
+		wg.Wait()
+		if err != nil {
+			return err
+		}
+		for _, err := range errs {
+			if err == nil || err == io.ErrClosedPipe {
+				continue
+			}
+			if opt.Mode == PasteShortest && brokenPipe(err) {
+				continue
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+// brokenPipe reports whether err is the failure of a pipe writing to a closed
+// pipe, either directly or as a command killed by SIGPIPE.  In a pipe.Errors
+// commands killed as a consequence, as pipe.Line does, are also allowed.  The
+// errors of pipe.Exec can only be recognized by their message.
+func brokenPipe(err error) bool {
+	if errs, ok := err.(pipe.Errors); ok {
+		broken := false
+		for _, err := range errs {
+			switch {
+			case brokenPipe(err):
+				broken = true
+			case !strings.HasSuffix(err.Error(), ": signal: "+syscall.SIGKILL.String()):
+				return false
+			}
+		}
+		return broken
+	}
+	if err == io.ErrClosedPipe || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	return strings.HasSuffix(err.Error(), ": signal: "+syscall.SIGPIPE.String())
+}
+
+func paste(w io.Writer, readers []*lineReader, opt *PasteOptions) error {
+	term := opt.Lines.Delim
+	if len(term) == 0 {
+		term = []byte{'\n'}
+	}
+	bw := bufio.NewWriter(w)
+	done := make([]bool, len(readers))
+	cols := make([][]byte, len(readers))
+	for {
+		ended := 0
+		for i, r := range readers {
+			cols[i] = cols[i][:0]
+			if done[i] {
+				ended++
+				continue
+			}
+			line, _, err := r.next()
+			if err == io.EOF {
+				done[i] = true
+				ended++
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			cols[i] = append(cols[i], line...)
+		}
+		switch {
+		case ended == len(readers):
+			return bw.Flush()
+		case ended > 0 && opt.Mode == PasteShortest:
+			return bw.Flush()
+		case ended > 0 && opt.Mode == PasteStrict:
+			err := bw.Flush()
+			if err != nil {
+				return err
+			}
+			return ErrUnequalLength
+		}
+		for i, col := range cols {
+			if i > 0 {
+				bw.WriteString(opt.Delim)
+			}
+			if done[i] {
+				bw.WriteString(opt.Fill)
+			} else {
+				bw.Write(col)
+			}
+		}
+		_, err := bw.Write(term)
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package nx
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/pipe.v2"
+)
+
+func TestPaste(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nx-paste-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(filepath.Join(dir, "ids"), []byte("1\n2\n3\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := pipe.ReadFile(filepath.Join(dir, "ids"))
+	long := pipe.Print(strings.Repeat("x\n", 10000))
+
+	for i, test := range []struct {
+		p       pipe.Pipe
+		in, out string
+		err     error
+	}{
+		{Paste(",", ids), "a\nb\nc\n", "a,1\nb,2\nc,3\n", nil},
+		{Paste("", ids, pipe.Print("p\nq")), "a\n", "a\t1\tp\n\t2\tq\n\t3\t\n", nil},
+		{Zip(ids), "a\nb\n", "a\t1\nb\t2\n", nil},
+		{Zip(long), "a\n", "a\tx\n", nil},
+		{Zip(pipe.Exec("seq", "1000000")), "a\n", "a\t1\n", nil},
+		{Zip(pipe.Line(pipe.Exec("seq", "1000000"), Tr("0-9", "a-j", nil))), "a\n", "a\tb\n", nil},
+		{PasteWith(&PasteOptions{Fill: "-", Delim: " "}, ids), "a\n", "a 1\n- 2\n- 3\n", nil},
+		{PasteWith(&PasteOptions{Mode: PasteStrict}, ids), "a\nb\nc", "a\t1\nb\t2\nc\t3\n", nil},
+		{PasteWith(&PasteOptions{Mode: PasteStrict}, ids), "a\n", "a\t1\n", ErrUnequalLength},
+		{PasteWith(&PasteOptions{Lines: NULLines}, pipe.Print("1\x002\x00")), "a\x00b\x00", "a\t1\x00b\t2\x00", nil},
+		{Paste(",", pipe.Exec("seq", "2")), "a\nb\n", "a,1\nb,2\n", nil},
+		{Paste(",", pipe.Line(pipe.Print("x\ny\n"), Tr("a-z", "A-Z", nil))), "a\n", "a,X\n,Y\n", nil},
+		{pipe.Line(Tr("a-z", "A-Z", nil), Zip(ids)), "a\nb\n", "A\t1\nB\t2\n", nil},
+	} {
+		out, err := runPipe(test.p, test.in)
+		if err != test.err {
+			t.Errorf("test %d: error %v (expected %v)", i, err, test.err)
+		}
+		if out != test.out {
+			t.Errorf("test %d: output %q (expected %q)", i, out, test.out)
+		}
+	}
+
+	fail := errors.New("fail")
+	_, err = runPipe(Paste(",", Error(fail)), "a\n")
+	if err != fail {
+		t.Errorf("error %v (expected %v)", err, fail)
+	}
+	_, err = runPipe(Zip(pipe.Exec("false")), "a\n")
+	if err == nil {
+		t.Errorf("failed source ignored")
+	}
+}
@@ -0,0 +1,164 @@
+package nx
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"math"
+	"unicode/utf8"
+
+	"gopkg.in/pipe.v2"
+)
+
+// The rune-oriented pipes count UTF-8 encoded runes instead of bytes.  Bytes
+// that are not valid UTF-8 are counted as one rune each and are emitted
+// unmodified.
+
+// FirstRunes returns a pipe.Pipe that emits the first n runes read from stdin
+// to stdout.
+func FirstRunes(n int64) pipe.Pipe {
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		w := bufio.NewWriter(p.Stdout)
+		err := copyRunes(w, bufio.NewReader(p.Stdin), n)
+		if err != nil {
+			return err
+		}
+		return w.Flush()
+	})
+}
+
+// TrimFirstRunes returns a pipe.Pipe that emits all but the first n runes
+// read from stdin to stdout.
+func TrimFirstRunes(n int64) pipe.Pipe {
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		r := bufio.NewReader(p.Stdin)
+		err := copyRunes(ioutil.Discard, r, n)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(p.Stdout, r)
+		return err
+	})
+}
+
+// LastRunes returns a pipe.Pipe that emits the last n runes read from stdin
+// before encountering EOF to stdout.  At most n*utf8.UTFMax bytes are
+// buffered.  If stdin is a regular file LastRunes reads only the end of the
+// file.
+func LastRunes(n int64) pipe.Pipe {
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		if n <= 0 {
+			_, err := io.Copy(ioutil.Discard, p.Stdin)
+			return err
+		}
+		if f, ok := regularFile(p.Stdin); ok {
+			err := seekTail(f, runeBytes(n))
+			if err != nil {
+				return err
+			}
+		}
+		ring := newByteRing(runeBytes(n), nil)
+		_, err := io.Copy(ring, p.Stdin)
+		if err != nil {
+			return err
+		}
+		tail := ring.Bytes()
+		_, err = p.Stdout.Write(tail[lastRunesIndex(tail, n):])
+		return err
+	})
+}
+
+// TrimLastRunes returns a pipe.Pipe that emits all but the last n runes read
+// from stdin to stdout.  At most n*utf8.UTFMax bytes are buffered.
+func TrimLastRunes(n int64) pipe.Pipe {
+	if n <= 0 {
+		return pipe.Tee(ioutil.Discard)
+	}
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		w := bufio.NewWriter(p.Stdout)
+		ring := newByteRing(runeBytes(n), w)
+		_, err := io.Copy(ring, p.Stdin)
+		if err != nil {
+			return err
+		}
+		tail := ring.Bytes()
+		_, err = w.Write(tail[:lastRunesIndex(tail, n)])
+		if err != nil {
+			return err
+		}
+		return w.Flush()
+	})
+}
+
+// runeBytes returns the greatest number of bytes that may encode n runes.
+func runeBytes(n int64) int64 {
+	if n > math.MaxInt64/utf8.UTFMax {
+		return math.MaxInt64
+	}
+	return n * utf8.UTFMax
+}
+
+// seekTail positions f so that at most n bytes remain to be read.
+func seekTail(f io.Seeker, n int64) error {
+	cur, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	end, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	off := end - n
+	if off < cur {
+		off = cur
+	}
+	_, err = f.Seek(off, io.SeekStart)
+	return err
+}
+
+// copyRunes copies n runes from r to w.
+func copyRunes(w io.Writer, r *bufio.Reader, n int64) error {
+	for n > 0 {
+		_, err := r.Peek(1)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		b, _ := r.Peek(r.Buffered())
+		var i int
+		for i < len(b) && n > 0 && utf8.FullRune(b[i:]) {
+			_, size := utf8.DecodeRune(b[i:])
+			i += size
+			n--
+		}
+		if i == 0 {
+			// a rune is split at the end of the buffer.  Peek returns less
+			// than UTFMax bytes only at EOF, where DecodeRune treats the
+			// partial encoding as invalid.
+			b, err = r.Peek(utf8.UTFMax)
+			if err != nil && err != io.EOF {
+				return err
+			}
+			_, i = utf8.DecodeRune(b)
+			n--
+		}
+		_, err = w.Write(b[:i])
+		if err != nil {
+			return err
+		}
+		r.Discard(i)
+	}
+	return nil
+}
+
+// lastRunesIndex returns the index in b where the last n runes of b begin.
+func lastRunesIndex(b []byte, n int64) int {
+	i := len(b)
+	for ; n > 0 && i > 0; n-- {
+		_, size := utf8.DecodeLastRune(b[:i])
+		i -= size
+	}
+	return i
+}
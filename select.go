@@ -0,0 +1,132 @@
+package nx
+
+import (
+	"bufio"
+	"io"
+	"math/rand"
+	"sort"
+
+	"gopkg.in/pipe.v2"
+)
+
+// Range returns a pipe.Pipe that emits lines from through to read from stdin
+// to stdout, like `sed -n 'from,to p'`.  Lines are numbered starting from 1.
+// If to is negative all lines starting with from are emitted.  Range stops
+// reading input after line to.  Lines are split according to opts, see
+// LineOptions.
+func Range(from, to int, opts ...LineOptions) pipe.Pipe {
+	opt := lineOptions(opts)
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		r := newLineReader(p.Stdin, opt)
+		w := bufio.NewWriter(p.Stdout)
+		for i := 1; to < 0 || i <= to; i++ {
+			line, term, err := r.next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if i < from {
+				continue
+			}
+			_, err = w.Write(line)
+			if err == nil {
+				_, err = w.Write(term)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return w.Flush()
+	})
+}
+
+// Every returns a pipe.Pipe that emits every nth line read from stdin to
+// stdout beginning with the line following the first offset lines.  Lines are
+// split according to opts, see LineOptions.
+func Every(n, offset int, opts ...LineOptions) pipe.Pipe {
+	if n <= 0 {
+		n = 1
+	}
+	opt := lineOptions(opts)
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		r := newLineReader(p.Stdin, opt)
+		w := bufio.NewWriter(p.Stdout)
+		for i := 0; ; i++ {
+			line, term, err := r.next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if i < offset || (i-offset)%n != 0 {
+				continue
+			}
+			_, err = w.Write(line)
+			if err == nil {
+				_, err = w.Write(term)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return w.Flush()
+	})
+}
+
+// Sample returns a pipe.Pipe that emits a uniformly random sample of k lines
+// read from stdin to stdout using reservoir sampling, so memory use is
+// proportional to k regardless of the amount of input.  Sampled lines are
+// emitted in the order they were read.  The sample is determined by seed so
+// that output is reproducible.  Lines are split according to opts, see
+// LineOptions.
+func Sample(k int, seed int64, opts ...LineOptions) pipe.Pipe {
+	opt := lineOptions(opts)
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		if k <= 0 {
+			return nil
+		}
+		type sampled struct {
+			i    int
+			line []byte
+		}
+		rng := rand.New(rand.NewSource(seed))
+		reservoir := make([]sampled, 0, k)
+		r := newLineReader(p.Stdin, opt)
+		for i := 0; ; i++ {
+			line, term, err := r.next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			j := i
+			if i >= k {
+				j = rng.Intn(i + 1)
+				if j >= k {
+					continue
+				}
+			}
+			ln := append(append([]byte(nil), line...), term...)
+			if i < k {
+				reservoir = append(reservoir, sampled{i, ln})
+			} else {
+				reservoir[j] = sampled{i, ln}
+			}
+		}
+		sort.Slice(reservoir, func(a, b int) bool {
+			return reservoir[a].i < reservoir[b].i
+		})
+		w := bufio.NewWriter(p.Stdout)
+		for _, s := range reservoir {
+			_, err := w.Write(s.line)
+			if err != nil {
+				return err
+			}
+		}
+		return w.Flush()
+	})
+}
@@ -0,0 +1,88 @@
+package nx
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRange(t *testing.T) {
+	in := "1\n2\n3\n4\n5\n"
+	for i, test := range []struct {
+		from, to int
+		out      string
+	}{
+		{2, 3, "2\n3\n"},
+		{4, -1, "4\n5\n"},
+		{1, 1, "1\n"},
+		{6, 10, ""},
+		{3, 2, ""},
+	} {
+		out, err := runPipe(Range(test.from, test.to), in)
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+		}
+		if out != test.out {
+			t.Errorf("test %d: %q (expected %q)", i, out, test.out)
+		}
+	}
+}
+
+func TestEvery(t *testing.T) {
+	in := "1\n2\n3\n4\n5\n"
+	for i, test := range []struct {
+		n, offset int
+		out       string
+	}{
+		{2, 0, "1\n3\n5\n"},
+		{2, 1, "2\n4\n"},
+		{3, 1, "2\n5\n"},
+		{1, 4, "5\n"},
+	} {
+		out, err := runPipe(Every(test.n, test.offset), in)
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+		}
+		if out != test.out {
+			t.Errorf("test %d: %q (expected %q)", i, out, test.out)
+		}
+	}
+}
+
+func TestSample(t *testing.T) {
+	var lines []string
+	for i := 0; i < 1000; i++ {
+		lines = append(lines, fmt.Sprint(i))
+	}
+	in := strings.Join(lines, "\n") + "\n"
+
+	out1, err := runPipe(Sample(10, 1), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out2, err := runPipe(Sample(10, 1), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out1 != out2 {
+		t.Errorf("sample is not deterministic: %q %q", out1, out2)
+	}
+	sample := strings.Split(strings.TrimSuffix(out1, "\n"), "\n")
+	if len(sample) != 10 {
+		t.Errorf("sample size %d", len(sample))
+	}
+	prev := -1
+	for _, s := range sample {
+		var n int
+		fmt.Sscan(s, &n)
+		if n <= prev {
+			t.Errorf("sample out of order: %q", sample)
+		}
+		prev = n
+	}
+
+	out, err := runPipe(Sample(10, 1), "a\nb\n")
+	if err != nil || out != "a\nb\n" {
+		t.Errorf("small input: %q %v", out, err)
+	}
+}
@@ -0,0 +1,565 @@
+package nx
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+
+	"gopkg.in/pipe.v2"
+)
+
+// SortMode determines how Sort compares lines or fields.
+type SortMode int
+
+// Available SortMode values.
+const (
+	// SortLexical compares bytes, like the default behavior of sort(1).
+	SortLexical SortMode = iota
+	// SortNumeric compares leading decimal numbers, like `sort -n`.  Values
+	// that are not numbers compare as zero.
+	SortNumeric
+	// SortVersion compares runs of digits numerically and other text
+	// lexically, like `sort -V`.
+	SortVersion
+	// SortHumanSize compares numbers with an optional SI suffix (K, M, G, T,
+	// P or E, as powers of 1024), like `sort -h`.
+	SortHumanSize
+)
+
+// SortKey selects a field to compare when sorting.
+type SortKey struct {
+	// Field is the field compared, starting from 1.  If Field is zero the
+	// entire line is compared.
+	Field int
+
+	// Mode determines how the field is compared.
+	Mode SortMode
+
+	// Reverse reverses the ordering of the key.
+	Reverse bool
+}
+
+// SortOptions configures Sort.  The zero value sorts lines lexically.
+type SortOptions struct {
+	// Keys are compared in order to determine the ordering of lines.  If Keys
+	// is empty lines are compared with Mode.
+	Keys []SortKey
+
+	// Mode determines how lines are compared when Keys is empty.
+	Mode SortMode
+
+	// FieldSep separates fields.  If FieldSep is empty fields are separated
+	// by runs of spaces and tabs and leading blanks are ignored.
+	FieldSep []byte
+
+	// Reverse reverses the ordering of lines.
+	Reverse bool
+
+	// Stable leaves lines with equal keys in the order they were read.
+	// Otherwise, lines with equal keys are ordered by comparing their bytes.
+	Stable bool
+
+	// Unique emits only the first of lines with equal keys.
+	Unique bool
+
+	// MemoryLimit is the approximate number of bytes of input held in memory.
+	// Larger inputs are sorted in runs which are written to temporary files
+	// and merged.  If MemoryLimit is zero a limit of 64MB is used.
+	MemoryLimit int64
+
+	// TempDir is the directory, relative to the pipe.State Dir, that holds
+	// temporary files.  If TempDir is empty the State Dir is used.
+	TempDir string
+
+	// Lines determines how input is split into lines.  Sorted lines are
+	// terminated by Lines.Delim or '\n' if Lines.Delim is empty.
+	Lines LineOptions
+}
+
+const (
+	defaultSortMemory = 64 << 20
+	sortLineOverhead  = 64 // approximate memory used by each line in addition to its content
+	sortMaxMerge      = 64 // maximum number of runs merged at once
+)
+
+// Sort returns a pipe.Pipe that emits the lines read from stdin to stdout in
+// sorted order.  A nil opts sorts lines lexically.
+func Sort(opts *SortOptions) pipe.Pipe {
+	if opts == nil {
+		opts = &SortOptions{}
+	}
+	opt := *opts
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		dir := opt.TempDir
+		if dir != "" || p.Dir != "" {
+			dir = p.Path(dir)
+		}
+		s := &sorter{opt: &opt, dir: dir}
+		defer s.cleanup()
+		return s.sort(p.Stdin, p.Stdout)
+	})
+}
+
+// sorter implements an external merge sort.
+type sorter struct {
+	opt   *SortOptions
+	dir   string
+	lines [][]byte
+	size  int64
+	runs  []string
+}
+
+func (s *sorter) cleanup() {
+	for _, run := range s.runs {
+		os.Remove(run)
+	}
+}
+
+func (s *sorter) sort(r io.Reader, w io.Writer) error {
+	limit := s.opt.MemoryLimit
+	if limit <= 0 {
+		limit = defaultSortMemory
+	}
+	lr := newLineReader(r, s.opt.Lines)
+	for {
+		line, _, err := lr.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		s.lines = append(s.lines, append([]byte(nil), line...))
+		s.size += int64(len(line)) + sortLineOverhead
+		if s.size >= limit {
+			err := s.spill()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	s.sortLines()
+
+	for len(s.runs) > sortMaxMerge {
+		err := s.mergeRuns(sortMaxMerge)
+		if err != nil {
+			return err
+		}
+	}
+
+	srcs := make([]lineSource, 0, len(s.runs)+1)
+	for _, run := range s.runs {
+		f, err := os.Open(run)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		srcs = append(srcs, &runReader{r: bufio.NewReader(f)})
+	}
+	srcs = append(srcs, &sliceSource{lines: s.lines})
+
+	delim := s.opt.Lines.Delim
+	if len(delim) == 0 {
+		delim = []byte{'\n'}
+	}
+	bw := bufio.NewWriter(w)
+	var prev []byte
+	first := true
+	err := s.merge(srcs, func(line []byte) error {
+		if s.opt.Unique {
+			if !first && s.compareKeys(prev, line) == 0 {
+				return nil
+			}
+			prev = append(prev[:0], line...)
+			first = false
+		}
+		_, err := bw.Write(line)
+		if err == nil {
+			_, err = bw.Write(delim)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func (s *sorter) sortLines() {
+	less := func(i, j int) bool {
+		return s.compare(s.lines[i], s.lines[j]) < 0
+	}
+	if s.opt.Stable {
+		sort.SliceStable(s.lines, less)
+	} else {
+		sort.Slice(s.lines, less)
+	}
+}
+
+// spill writes the sorted lines in memory to a temporary file.
+func (s *sorter) spill() error {
+	s.sortLines()
+	f, err := ioutil.TempFile(s.dir, "nx-sort-")
+	if err != nil {
+		return err
+	}
+	s.runs = append(s.runs, f.Name())
+	w := bufio.NewWriter(f)
+	for _, line := range s.lines {
+		err = writeRunLine(w, line)
+		if err != nil {
+			f.Close()
+			return err
+		}
+	}
+	err = w.Flush()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.lines = s.lines[:0]
+	s.size = 0
+	return f.Close()
+}
+
+// mergeRuns merges the first n runs into a single run.  Because runs contain
+// consecutive input, the merged run takes the place of the first so that
+// Stable ordering is preserved.
+func (s *sorter) mergeRuns(n int) error {
+	srcs := make([]lineSource, n)
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for i, run := range s.runs[:n] {
+		f, err := os.Open(run)
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+		srcs[i] = &runReader{r: bufio.NewReader(f)}
+	}
+	out, err := ioutil.TempFile(s.dir, "nx-sort-")
+	if err != nil {
+		return err
+	}
+	files = append(files, out)
+	w := bufio.NewWriter(out)
+	err = s.merge(srcs, func(line []byte) error {
+		return writeRunLine(w, line)
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	for _, run := range s.runs[:n] {
+		os.Remove(run)
+	}
+	s.runs = append([]string{out.Name()}, s.runs[n:]...)
+	return err
+}
+
+// merge calls fn with the lines of srcs in sorted order.  Lines that compare
+// equal are taken from earlier srcs first.
+func (s *sorter) merge(srcs []lineSource, fn func(line []byte) error) error {
+	h := &mergeHeap{s: s}
+	for i, src := range srcs {
+		line, err := src.next()
+		if err == io.EOF {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		h.items = append(h.items, mergeItem{line, i, src})
+	}
+	heap.Init(h)
+	for h.Len() > 0 {
+		top := &h.items[0]
+		err := fn(top.line)
+		if err != nil {
+			return err
+		}
+		line, err := top.src.next()
+		if err == io.EOF {
+			heap.Pop(h)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		top.line = line
+		heap.Fix(h, 0)
+	}
+	return nil
+}
+
+// compare returns the ordering of a and b.
+func (s *sorter) compare(a, b []byte) int {
+	c := s.compareKeys(a, b)
+	if c != 0 || s.opt.Stable {
+		return c
+	}
+	c = bytes.Compare(a, b)
+	if s.opt.Reverse {
+		return -c
+	}
+	return c
+}
+
+// compareKeys returns the ordering of a and b considering only their keys.
+func (s *sorter) compareKeys(a, b []byte) int {
+	if len(s.opt.Keys) == 0 {
+		c := compareMode(s.opt.Mode, a, b)
+		if s.opt.Reverse {
+			return -c
+		}
+		return c
+	}
+	for _, key := range s.opt.Keys {
+		c := compareMode(key.Mode, s.field(a, key.Field), s.field(b, key.Field))
+		if key.Reverse != s.opt.Reverse {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// field returns field i of line, starting from 1, or line if i is zero.
+func (s *sorter) field(line []byte, i int) []byte {
+	if i <= 0 {
+		return line
+	}
+	return nthField(line, i, s.opt.FieldSep)
+}
+
+// nthField returns field i of line, starting from 1.  If sep is empty fields
+// are separated by runs of spaces and tabs.
+func nthField(line []byte, i int, sep []byte) []byte {
+	if len(sep) > 0 {
+		for ; i > 1; i-- {
+			j := bytes.Index(line, sep)
+			if j < 0 {
+				return nil
+			}
+			line = line[j+len(sep):]
+		}
+		if j := bytes.Index(line, sep); j >= 0 {
+			line = line[:j]
+		}
+		return line
+	}
+	for ; i > 0; i-- {
+		line = bytes.TrimLeft(line, " \t")
+		j := bytes.IndexAny(line, " \t")
+		if j < 0 {
+			if i == 1 {
+				return line
+			}
+			return nil
+		}
+		if i == 1 {
+			return line[:j]
+		}
+		line = line[j:]
+	}
+	return nil
+}
+
+func compareMode(mode SortMode, a, b []byte) int {
+	switch mode {
+	case SortNumeric:
+		return compareFloat(parseNumber(a), parseNumber(b))
+	case SortVersion:
+		return compareVersion(a, b)
+	case SortHumanSize:
+		return compareFloat(parseHumanSize(a), parseHumanSize(b))
+	}
+	return bytes.Compare(a, b)
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// numberPrefix returns the length of the decimal number beginning s, which
+// has had leading blanks removed.
+func numberPrefix(s []byte) int {
+	var i int
+	if i < len(s) && (s[i] == '-' || s[i] == '+') {
+		i++
+	}
+	var digits bool
+	for ; i < len(s) && '0' <= s[i] && s[i] <= '9'; i++ {
+		digits = true
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		for ; i < len(s) && '0' <= s[i] && s[i] <= '9'; i++ {
+			digits = true
+		}
+	}
+	if !digits {
+		return 0
+	}
+	return i
+}
+
+func parseNumber(s []byte) float64 {
+	s = bytes.TrimLeft(s, " \t")
+	n := numberPrefix(s)
+	if n == 0 {
+		return 0
+	}
+	f, err := strconv.ParseFloat(string(s[:n]), 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
+
+func parseHumanSize(s []byte) float64 {
+	s = bytes.TrimLeft(s, " \t")
+	n := numberPrefix(s)
+	if n == 0 {
+		return 0
+	}
+	f, err := strconv.ParseFloat(string(s[:n]), 64)
+	if err != nil {
+		return 0
+	}
+	if n < len(s) {
+		i := bytes.IndexByte([]byte("KMGTPE"), s[n]&^0x20)
+		for ; i >= 0; i-- {
+			f *= 1024
+		}
+	}
+	return f
+}
+
+// compareVersion compares runs of digits in a and b numerically and other
+// bytes lexically.
+func compareVersion(a, b []byte) int {
+	isDigit := func(c byte) bool { return '0' <= c && c <= '9' }
+	for len(a) > 0 && len(b) > 0 {
+		if isDigit(a[0]) && isDigit(b[0]) {
+			var i, j int
+			for i < len(a) && isDigit(a[i]) {
+				i++
+			}
+			for j < len(b) && isDigit(b[j]) {
+				j++
+			}
+			na := bytes.TrimLeft(a[:i], "0")
+			nb := bytes.TrimLeft(b[:j], "0")
+			if len(na) != len(nb) {
+				return compareFloat(float64(len(na)), float64(len(nb)))
+			}
+			if c := bytes.Compare(na, nb); c != 0 {
+				return c
+			}
+			a, b = a[i:], b[j:]
+			continue
+		}
+		if a[0] != b[0] {
+			return compareFloat(float64(a[0]), float64(b[0]))
+		}
+		a, b = a[1:], b[1:]
+	}
+	return compareFloat(float64(len(a)), float64(len(b)))
+}
+
+// lineSource produces sorted lines for merging.
+type lineSource interface {
+	next() ([]byte, error)
+}
+
+type sliceSource struct {
+	lines [][]byte
+}
+
+func (s *sliceSource) next() ([]byte, error) {
+	if len(s.lines) == 0 {
+		return nil, io.EOF
+	}
+	line := s.lines[0]
+	s.lines = s.lines[1:]
+	return line, nil
+}
+
+// runReader reads lines written by writeRunLine.  Lines are length-prefixed
+// so that they may contain any bytes.
+type runReader struct {
+	r *bufio.Reader
+}
+
+func (r *runReader) next() ([]byte, error) {
+	n, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return nil, err
+	}
+	line := make([]byte, n)
+	_, err = io.ReadFull(r.r, line)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return line, err
+}
+
+func writeRunLine(w *bufio.Writer, line []byte) error {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], uint64(len(line)))
+	_, err := w.Write(buf[:n])
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(line)
+	return err
+}
+
+type mergeItem struct {
+	line []byte
+	i    int
+	src  lineSource
+}
+
+type mergeHeap struct {
+	s     *sorter
+	items []mergeItem
+}
+
+func (h *mergeHeap) Len() int { return len(h.items) }
+
+func (h *mergeHeap) Less(i, j int) bool {
+	c := h.s.compare(h.items[i].line, h.items[j].line)
+	if c != 0 {
+		return c < 0
+	}
+	return h.items[i].i < h.items[j].i
+}
+
+func (h *mergeHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *mergeHeap) Push(x interface{}) { h.items = append(h.items, x.(mergeItem)) }
+
+func (h *mergeHeap) Pop() interface{} {
+	x := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return x
+}
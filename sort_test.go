@@ -0,0 +1,90 @@
+package nx
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"gopkg.in/pipe.v2"
+)
+
+func TestSort(t *testing.T) {
+	for i, test := range []struct {
+		opt     *SortOptions
+		in, out string
+	}{
+		{nil, "b\nc\na", "a\nb\nc\n"},
+		{&SortOptions{Reverse: true}, "b\nc\na\n", "c\nb\na\n"},
+		{&SortOptions{Mode: SortNumeric}, "10\n9\n-1\nx\n1.5\n", "-1\nx\n1.5\n9\n10\n"},
+		{&SortOptions{Mode: SortVersion}, "v1.10\nv1.9\nv1.9a\nv1.09\n", "v1.09\nv1.9\nv1.9a\nv1.10\n"},
+		{&SortOptions{Mode: SortHumanSize}, "1G\n10K\n2k\n512\n3M\n", "512\n2k\n10K\n3M\n1G\n"},
+		{&SortOptions{Keys: []SortKey{{Field: 2, Mode: SortNumeric}}}, "a 10\nb  2\n  c 1\n", "  c 1\nb  2\na 10\n"},
+		{&SortOptions{Keys: []SortKey{{Field: 2}, {Field: 1, Reverse: true}}, FieldSep: []byte(",")}, "a,x\nb,x\nc,a\n", "c,a\nb,x\na,x\n"},
+		{&SortOptions{Keys: []SortKey{{Field: 1}}, Stable: true}, "b 2\na 1\nb 1\na 2\n", "a 1\na 2\nb 2\nb 1\n"},
+		{&SortOptions{Keys: []SortKey{{Field: 1}}}, "b 2\na 2\nb 1\na 1\n", "a 1\na 2\nb 1\nb 2\n"},
+		{&SortOptions{Unique: true}, "b\na\nb\na\n", "a\nb\n"},
+		{&SortOptions{Unique: true, Keys: []SortKey{{Field: 1}}, Stable: true}, "b 2\na 1\nb 1\n", "a 1\nb 2\n"},
+		{&SortOptions{Lines: NULLines}, "b\x00a\nc\x00", "a\nc\x00b\x00"},
+	} {
+		out, err := runPipe(Sort(test.opt), test.in)
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+		}
+		if out != test.out {
+			t.Errorf("test %d: %q (expected %q)", i, out, test.out)
+		}
+	}
+}
+
+func TestSort_spill(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nx-sort-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rng := rand.New(rand.NewSource(1))
+	var lines []string
+	for i := 0; i < 5000; i++ {
+		lines = append(lines, fmt.Sprintf("%d %d", rng.Intn(100), i))
+	}
+	in := strings.Join(lines, "\n") + "\n"
+
+	opt := &SortOptions{
+		Keys:        []SortKey{{Field: 1, Mode: SortNumeric}},
+		Stable:      true,
+		MemoryLimit: 1 << 10,
+		TempDir:     "tmp",
+	}
+	err = os.Mkdir(dir+"/tmp", 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &pipe.OutputBuffer{}
+	s := newState(strings.NewReader(in), out)
+	s.Dir = dir
+	err = runTimeout(Sort(opt), s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool {
+		return parseNumber([]byte(lines[i])) < parseNumber([]byte(lines[j]))
+	})
+	expect := strings.Join(lines, "\n") + "\n"
+	if string(out.Bytes()) != expect {
+		t.Errorf("output does not match in memory sort")
+	}
+
+	tmp, err := ioutil.ReadDir(dir + "/tmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tmp) != 0 {
+		t.Errorf("%d temporary files remain", len(tmp))
+	}
+}
@@ -0,0 +1,253 @@
+package nx
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"regexp"
+
+	"gopkg.in/pipe.v2"
+)
+
+// SplitMode determines how Split divides its input into shards.
+type SplitMode int
+
+// Available SplitMode values.
+const (
+	// SplitLines starts a new shard every N lines, like `split -l`.
+	SplitLines SplitMode = iota
+	// SplitBytes starts a new shard before a line that would make the
+	// current shard exceed N bytes, like `split -C`.  A line longer than N
+	// bytes occupies a shard of its own.
+	SplitBytes
+	// SplitRegexp starts a new shard at each line matching Regexp, like
+	// csplit(1).  Lines preceding the first match form the first shard.
+	SplitRegexp
+	// SplitHash distributes lines among N shards by the hash of a key field,
+	// so that lines with equal keys are written to the same shard.
+	SplitHash
+)
+
+// SplitOptions configures Split.
+type SplitOptions struct {
+	// Mode determines how input is divided.
+	Mode SplitMode
+
+	// N is the number of lines or bytes in each shard, or the number of
+	// shards when Mode is SplitHash.
+	N int64
+
+	// Regexp matches the lines that start shards when Mode is SplitRegexp.
+	Regexp *regexp.Regexp
+
+	// Field is the key field hashed when Mode is SplitHash, starting from 1.
+	// If Field is zero the entire line is hashed.
+	Field int
+
+	// FieldDelim separates fields.  If FieldDelim is empty fields are
+	// separated by runs of spaces and tabs.
+	FieldDelim string
+
+	// Name is a fmt format string given the index of a shard, starting from
+	// 0, that produces its file name relative to the pipe.State Dir.  If Name
+	// is empty "x%02d" is used.
+	Name string
+
+	// Perm is the permission of created files, before the umask.  If Perm is
+	// zero 0666 is used.
+	Perm os.FileMode
+
+	// Encode, if not nil, filters the contents of each shard before it is
+	// written, as with nxgzip.Encode().
+	Encode pipe.Pipe
+
+	// Lines determines how input is split into lines.
+	Lines LineOptions
+}
+
+// Split returns a pipe.Pipe that writes the lines read from stdin to a
+// sequence of files, like split(1) and csplit(1).  The name of each file is
+// emitted to stdout, followed by a newline, when the file is created.  Files
+// are only created for shards that contain lines.
+func Split(opts *SplitOptions) pipe.Pipe {
+	if opts == nil {
+		opts = &SplitOptions{}
+	}
+	opt := *opts
+	if opt.Name == "" {
+		opt.Name = "x%02d"
+	}
+	if opt.Perm == 0 {
+		opt.Perm = 0666
+	}
+	return groupTaskFunc(func(p *pipe.State, g *stateGroup) error {
+		switch opt.Mode {
+		case SplitLines, SplitBytes, SplitHash:
+			if opt.N <= 0 {
+				return errors.New("nx: split requires a positive N")
+			}
+		case SplitRegexp:
+			if opt.Regexp == nil {
+				return errors.New("nx: split requires a Regexp")
+			}
+		}
+		s := &splitter{p: p, g: g, opt: &opt}
+		err := s.split()
+		cerr := s.closeAll()
+		if err != nil {
+			return err
+		}
+		return cerr
+	})
+}
+
+// splitter writes lines to shards for Split.
+type splitter struct {
+	p      *pipe.State
+	g      *stateGroup // runs Encode for each shard
+	opt    *SplitOptions
+	shards map[int]*shard
+	cur    int   // index of the shard receiving the current line
+	count  int64 // lines or bytes written to the current shard
+}
+
+func (s *splitter) split() error {
+	s.shards = make(map[int]*shard)
+	fopt := &FieldOptions{Delim: s.opt.FieldDelim}
+	r := newLineReader(s.p.Stdin, s.opt.Lines)
+	for {
+		line, term, err := r.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		n := int64(len(line) + len(term))
+		switch s.opt.Mode {
+		case SplitLines:
+			if s.count >= s.opt.N {
+				err = s.next()
+			}
+			s.count++
+		case SplitBytes:
+			if s.count > 0 && s.count+n > s.opt.N {
+				err = s.next()
+			}
+			s.count += n
+		case SplitRegexp:
+			if s.count > 0 && s.opt.Regexp.Match(line) {
+				err = s.next()
+			}
+			s.count++
+		case SplitHash:
+			key := line
+			if s.opt.Field > 0 {
+				key = nil
+				if fields := fopt.split(line); s.opt.Field <= len(fields) {
+					key = fields[s.opt.Field-1]
+				}
+			}
+			h := fnv.New32a()
+			h.Write(key)
+			s.cur = int(h.Sum32() % uint32(s.opt.N))
+		}
+		if err != nil {
+			return err
+		}
+		sh, err := s.shard(s.cur)
+		if err != nil {
+			return err
+		}
+		_, err = sh.w.Write(line)
+		if err == nil {
+			_, err = sh.w.Write(term)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// next closes the current shard and starts the following one.
+func (s *splitter) next() error {
+	sh := s.shards[s.cur]
+	delete(s.shards, s.cur)
+	s.cur++
+	s.count = 0
+	if sh == nil {
+		return nil
+	}
+	return sh.close()
+}
+
+// shard returns shard i, creating its file if necessary.
+func (s *splitter) shard(i int) (*shard, error) {
+	if sh := s.shards[i]; sh != nil {
+		return sh, nil
+	}
+	name := fmt.Sprintf(s.opt.Name, i)
+	f, err := os.OpenFile(s.p.Path(name), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, s.opt.Perm)
+	if err != nil {
+		return nil, err
+	}
+	sh := &shard{f: f}
+	if s.opt.Encode == nil {
+		sh.w = bufio.NewWriter(f)
+	} else {
+		pr, pw := io.Pipe()
+		sh.pw = pw
+		sh.w = bufio.NewWriter(pw)
+		sh.done = make(chan error, 1)
+		state := newSubState(s.p, pr, f, s.p.Stderr)
+		go func() {
+			err := s.g.run(s.opt.Encode, state)
+			pr.CloseWithError(err)
+			sh.done <- err
+		}()
+	}
+	s.shards[i] = sh
+	_, err = fmt.Fprintln(s.p.Stdout, name)
+	if err != nil {
+		return nil, err
+	}
+	return sh, nil
+}
+
+// closeAll closes every open shard and returns the first error encountered.
+func (s *splitter) closeAll() error {
+	var first error
+	for i, sh := range s.shards {
+		err := sh.close()
+		if first == nil {
+			first = err
+		}
+		delete(s.shards, i)
+	}
+	return first
+}
+
+// shard is an output file of Split.
+type shard struct {
+	f    *os.File
+	w    *bufio.Writer
+	pw   *io.PipeWriter // writes to Encode, nil if shards are not encoded
+	done chan error     // receives the result of Encode
+}
+
+func (sh *shard) close() error {
+	err := sh.w.Flush()
+	if sh.pw != nil {
+		sh.pw.Close()
+		if eerr := <-sh.done; err == nil {
+			err = eerr
+		}
+	}
+	if cerr := sh.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,135 @@
+package nx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/bmatsuo/nx/nxcompress/nxgzip"
+	"gopkg.in/pipe.v2"
+)
+
+func TestSplit(t *testing.T) {
+	for i, test := range []struct {
+		opt   SplitOptions
+		in    string
+		files []string
+	}{
+		{SplitOptions{N: 2}, "a\nb\nc\nd\ne", []string{"a\nb\n", "c\nd\n", "e"}},
+		{SplitOptions{Mode: SplitBytes, N: 5}, "aa\nbb\ncccccc\nd\n", []string{"aa\n", "bb\n", "cccccc\n", "d\n"}},
+		{SplitOptions{Mode: SplitBytes, N: 6}, "aa\nbb\nc\n", []string{"aa\nbb\n", "c\n"}},
+		{SplitOptions{Mode: SplitRegexp, Regexp: regexp.MustCompile(`^#`)}, "#1\na\n#2\nb\nc\n", []string{"#1\na\n", "#2\nb\nc\n"}},
+		{SplitOptions{Mode: SplitRegexp, Regexp: regexp.MustCompile(`^#`)}, "x\n#1\n", []string{"x\n", "#1\n"}},
+	} {
+		dir, err := ioutil.TempDir("", "nx-split-test-")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		var names bytes.Buffer
+		s := newState(strings.NewReader(test.in), &names)
+		s.Dir = dir
+		err = runTimeout(Split(&test.opt), s)
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+			continue
+		}
+		listed := strings.Fields(names.String())
+		if len(listed) != len(test.files) {
+			t.Errorf("test %d: files %q (expected %d)", i, listed, len(test.files))
+			continue
+		}
+		for j, name := range listed {
+			b, err := ioutil.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				t.Errorf("test %d: %v", i, err)
+			} else if string(b) != test.files[j] {
+				t.Errorf("test %d: file %s %q (expected %q)", i, name, b, test.files[j])
+			}
+		}
+	}
+}
+
+func TestSplitHash(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nx-split-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	in := "a 1\nb 2\na 3\nc 4\nb 5\n"
+	opt := &SplitOptions{
+		Mode:   SplitHash,
+		N:      4,
+		Field:  1,
+		Name:   "shard-%d.gz",
+		Encode: nxgzip.Encode(),
+	}
+	var names bytes.Buffer
+	s := newState(strings.NewReader(in), &names)
+	s.Dir = dir
+	err = runTimeout(Split(opt), s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := make(map[string]string)
+	var total int
+	for _, name := range strings.Fields(names.String()) {
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(r)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(string(b), "\n"), "\n") {
+			total++
+			key := strings.Fields(line)[0]
+			if prev, ok := keys[key]; ok && prev != name {
+				t.Errorf("key %s in %s and %s", key, prev, name)
+			}
+			keys[key] = name
+		}
+	}
+	if total != 5 {
+		t.Errorf("%d lines written (expected 5)", total)
+	}
+}
+
+func TestSplitEncode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nx-split-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	opt := &SplitOptions{N: 1, Encode: pipe.Exec("tr", "a-z", "A-Z")}
+	out, err := runPipe(pipe.Script(pipe.ChDir(dir), pipe.Line(pipe.Print("a\nb\n"), Split(opt))), "")
+	if err != nil || out != "x00\nx01\n" {
+		t.Fatalf("output %q, error %v", out, err)
+	}
+	for name, content := range map[string]string{"x00": "A\n", "x01": "B\n"} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(b) != content {
+			t.Errorf("file %s %q, error %v", name, b, err)
+		}
+	}
+
+	fail := errors.New("fail")
+	opt.Encode = pipe.TaskFunc(func(*pipe.State) error { return fail })
+	opt.Name = "y%02d"
+	_, err = runPipe(pipe.Script(pipe.ChDir(dir), pipe.Line(pipe.Print("a\nb\n"), Split(opt))), "")
+	if err != fail {
+		t.Errorf("error %v (expected %v)", err, fail)
+	}
+}
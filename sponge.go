@@ -0,0 +1,143 @@
+package nx
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"gopkg.in/pipe.v2"
+)
+
+// SpongeOptions configures Sponge.
+type SpongeOptions struct {
+	// MemoryLimit is the number of bytes of input held in memory.  Input
+	// exceeding the limit is written to a temporary file.  If MemoryLimit is
+	// zero a limit of 64MB is used.
+	MemoryLimit int64
+
+	// TempDir is the directory, relative to the pipe.State Dir, that holds
+	// temporary files.  If TempDir is empty the State Dir is used.
+	TempDir string
+}
+
+const defaultSpongeMemory = 64 << 20
+
+// Sponge returns a pipe.Pipe that reads all input from stdin before writing
+// any of it to stdout, like sponge(1).  A nil opts is equivalent to a zero
+// SpongeOptions.  To rewrite a file read earlier in a pipeline use
+// WriteFileAtomic, which does not replace the file until input is exhausted.
+func Sponge(opts *SpongeOptions) pipe.Pipe {
+	if opts == nil {
+		opts = &SpongeOptions{}
+	}
+	opt := *opts
+	if opt.MemoryLimit <= 0 {
+		opt.MemoryLimit = defaultSpongeMemory
+	}
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		var buf bytes.Buffer
+		n, err := io.CopyN(&buf, p.Stdin, opt.MemoryLimit+1)
+		if err == io.EOF || err == nil && n <= opt.MemoryLimit {
+			_, err = buf.WriteTo(p.Stdout)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		dir := opt.TempDir
+		if dir != "" || p.Dir != "" {
+			dir = p.Path(dir)
+		}
+		f, err := ioutil.TempFile(dir, "nx-sponge-")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+		_, err = buf.WriteTo(f)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, p.Stdin)
+		if err != nil {
+			return err
+		}
+		_, err = f.Seek(0, io.SeekStart)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(p.Stdout, f)
+		return err
+	})
+}
+
+// WriteFileAtomic returns a pipe.Pipe that writes the input read from stdin
+// to the file at path, relative to the pipe.State Dir, replacing it
+// atomically.  Input is written to a temporary file in the same directory,
+// which is synced to disk and renamed to path once input is exhausted, so
+// readers never observe a partially written file and a pipeline may safely
+// rewrite a file it reads.  The file is given permissions perm, unaffected
+// by the umask.  On failure the original file is left unchanged.
+func WriteFileAtomic(path string, perm os.FileMode) pipe.Pipe {
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		target := p.Path(path)
+		dir, base := filepath.Split(target)
+		if dir == "" {
+			dir = "."
+		}
+		f, err := ioutil.TempFile(dir, "."+base+".tmp-")
+		if err != nil {
+			return err
+		}
+		tmp := f.Name()
+		err = writeSynced(f, p.Stdin, perm)
+		if err == nil {
+			err = os.Rename(tmp, target)
+		}
+		if err != nil {
+			os.Remove(tmp)
+			return err
+		}
+		return syncDir(dir)
+	})
+}
+
+// writeSynced copies r to f, sets its permissions, syncs it to disk and
+// closes it.
+func writeSynced(f *os.File, r io.Reader, perm os.FileMode) error {
+	_, err := io.Copy(f, r)
+	if err == nil {
+		err = f.Chmod(perm)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	cerr := f.Close()
+	if err != nil {
+		return err
+	}
+	return cerr
+}
+
+// syncDir syncs the directory dir so that a rename within it is durable.
+// Directories cannot be synced on windows, where renames are not made
+// durable.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	cerr := d.Close()
+	if err != nil {
+		return err
+	}
+	return cerr
+}
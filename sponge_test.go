@@ -0,0 +1,115 @@
+package nx
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/pipe.v2"
+)
+
+// watchWriter records whether it was written to before reader finished.
+type watchWriter struct {
+	bytes.Buffer
+	done  *bool
+	early bool
+}
+
+func (w *watchWriter) Write(b []byte) (int, error) {
+	if !*w.done {
+		w.early = true
+	}
+	return w.Buffer.Write(b)
+}
+
+type doneReader struct {
+	r    io.Reader
+	done bool
+}
+
+func (r *doneReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	if err == io.EOF {
+		r.done = true
+	}
+	return n, err
+}
+
+func TestSponge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nx-sponge-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	in := strings.Repeat("0123456789", 1000)
+	for i, opt := range []*SpongeOptions{nil, {MemoryLimit: 100, TempDir: dir}} {
+		r := &doneReader{r: strings.NewReader(in)}
+		w := &watchWriter{done: &r.done}
+		err := runTimeout(Sponge(opt), newState(r, w))
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+		}
+		if w.String() != in {
+			t.Errorf("test %d: output mismatch", i)
+		}
+		if w.early {
+			t.Errorf("test %d: output written before input was exhausted", i)
+		}
+	}
+	left, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(left) != 0 {
+		t.Errorf("temporary files remain: %q", left)
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nx-sponge-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "f")
+	err = ioutil.WriteFile(path, []byte("b\na\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newState(nil, nil)
+	s.Dir = dir
+	err = runTimeout(pipe.Line(pipe.ReadFile("f"), Sort(nil), WriteFileAtomic("f", 0600)), s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil || string(b) != "a\nb\n" {
+		t.Errorf("content %q, error %v", b, err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("mode %v, error %v", info.Mode(), err)
+	}
+
+	fail := errors.New("fail")
+	r := io.MultiReader(strings.NewReader("partial"), errReader{fail})
+	s = newState(r, nil)
+	s.Dir = dir
+	err = runTimeout(WriteFileAtomic("f", 0644), s)
+	if err != fail {
+		t.Errorf("error %v (expected %v)", err, fail)
+	}
+	b, _ = ioutil.ReadFile(path)
+	if string(b) != "a\nb\n" {
+		t.Errorf("file modified after failure: %q", b)
+	}
+	left, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(left) != 1 {
+		t.Errorf("temporary files remain: %q", left)
+	}
+}
+
+type errReader struct{ err error }
+
+func (r errReader) Read(b []byte) (int, error) { return 0, r.err }
@@ -0,0 +1,138 @@
+package nx
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"gopkg.in/pipe.v2"
+)
+
+// TacOptions configures Tac.
+type TacOptions struct {
+	// MemoryLimit is the approximate number of bytes of input held in memory.
+	// When input exceeds the limit, lines are written in reverse order to
+	// temporary files.  If MemoryLimit is zero a limit of 64MB is used.
+	MemoryLimit int64
+
+	// TempDir is the directory, relative to the pipe.State Dir, that holds
+	// temporary files.  If TempDir is empty the State Dir is used.
+	TempDir string
+
+	// Lines determines how input is split into lines.
+	Lines LineOptions
+}
+
+const defaultTacMemory = 64 << 20
+
+// Tac returns a pipe.Pipe that emits the lines read from stdin to stdout in
+// reverse order, like tac(1).  Each line is emitted with the terminator it
+// was read with.  A nil opts is equivalent to a zero TacOptions.
+func Tac(opts *TacOptions) pipe.Pipe {
+	if opts == nil {
+		opts = &TacOptions{}
+	}
+	opt := *opts
+	if opt.MemoryLimit <= 0 {
+		opt.MemoryLimit = defaultTacMemory
+	}
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		dir := opt.TempDir
+		if dir != "" || p.Dir != "" {
+			dir = p.Path(dir)
+		}
+		t := &tacker{opt: &opt, dir: dir}
+		defer t.cleanup()
+		return t.tac(p.Stdin, p.Stdout)
+	})
+}
+
+// tacker reverses input, spilling reversed chunks of lines to temporary
+// files.  Chunks are emitted in the reverse of the order they were read.
+type tacker struct {
+	opt    *TacOptions
+	dir    string
+	lines  [][]byte // lines including their terminators
+	size   int64
+	chunks []string
+}
+
+func (t *tacker) cleanup() {
+	for _, chunk := range t.chunks {
+		os.Remove(chunk)
+	}
+}
+
+func (t *tacker) tac(r io.Reader, w io.Writer) error {
+	lr := newLineReader(r, t.opt.Lines)
+	for {
+		line, term, err := lr.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		ln := make([]byte, 0, len(line)+len(term))
+		ln = append(append(ln, line...), term...)
+		t.lines = append(t.lines, ln)
+		t.size += int64(len(ln)) + sortLineOverhead
+		if t.size >= t.opt.MemoryLimit {
+			err := t.spill()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	err := t.writeLines(bw)
+	if err != nil {
+		return err
+	}
+	for i := len(t.chunks) - 1; i >= 0; i-- {
+		f, err := os.Open(t.chunks[i])
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(bw, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// writeLines writes the lines in memory in reverse order.
+func (t *tacker) writeLines(w *bufio.Writer) error {
+	for i := len(t.lines) - 1; i >= 0; i-- {
+		_, err := w.Write(t.lines[i])
+		if err != nil {
+			return err
+		}
+	}
+	t.lines = t.lines[:0]
+	t.size = 0
+	return nil
+}
+
+// spill writes the lines in memory in reverse order to a temporary file.
+func (t *tacker) spill() error {
+	f, err := ioutil.TempFile(t.dir, "nx-tac-")
+	if err != nil {
+		return err
+	}
+	t.chunks = append(t.chunks, f.Name())
+	w := bufio.NewWriter(f)
+	err = t.writeLines(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
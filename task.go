@@ -0,0 +1,109 @@
+package nx
+
+import (
+	"io"
+	"sync"
+
+	"gopkg.in/pipe.v2"
+)
+
+// newSubState returns a pipe.State for running a pipe from within a task
+// given p, sharing the directory and environment of p.  A nil stdin reads no
+// input.
+func newSubState(p *pipe.State, stdin io.Reader, stdout, stderr io.Writer) *pipe.State {
+	s := pipe.NewState(stdout, stderr)
+	if stdin != nil {
+		s.Stdin = stdin
+	}
+	s.Dir = p.Dir
+	s.Env = append([]string(nil), p.Env...)
+	return s
+}
+
+// runState runs p with s along with the tasks it registers, like pipe.Run.
+// When a single task fails its error is returned as is rather than as
+// pipe.Errors.
+func runState(p pipe.Pipe, s *pipe.State) error {
+	err := p(s)
+	if err != nil {
+		return err
+	}
+	return runTasks(s)
+}
+
+// runTasks runs the tasks registered with s, returning errors as runState
+// does.
+func runTasks(s *pipe.State) error {
+	err := s.RunTasks()
+	if errs, ok := err.(pipe.Errors); ok && len(errs) == 1 {
+		return errs[0]
+	}
+	return err
+}
+
+// stateGroup runs pipes with states that are killed along with the task
+// running them.
+type stateGroup struct {
+	mu     sync.Mutex
+	killed bool
+	states map[*pipe.State]bool
+}
+
+// run runs p with s like runState.  If the group has been killed the tasks
+// of p are not run and pipe.ErrKilled is returned.  The state is killable only
+// once p has been set up, as a pipe.State must not be killed while tasks are
+// being added to it.
+func (g *stateGroup) run(p pipe.Pipe, s *pipe.State) error {
+	err := p(s)
+	if err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	if g.killed {
+		g.mu.Unlock()
+		return pipe.ErrKilled
+	}
+	if g.states == nil {
+		g.states = make(map[*pipe.State]bool)
+	}
+	g.states[s] = true
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.states, s)
+		g.mu.Unlock()
+	}()
+	return runTasks(s)
+}
+
+// Kill kills the states running pipes and prevents more from running.
+func (g *stateGroup) Kill() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.killed = true
+	for s := range g.states {
+		s.Kill()
+	}
+}
+
+// groupTask is a pipe.Task that runs fn with a stateGroup that is killed
+// when the task is.
+type groupTask struct {
+	stateGroup
+	fn func(p *pipe.State, g *stateGroup) error
+}
+
+func (t *groupTask) Run(p *pipe.State) error {
+	return t.fn(p, &t.stateGroup)
+}
+
+// groupTaskFunc returns a pipe.Pipe that adds a task running fn, like
+// pipe.TaskFunc.  Pipes fn runs using the stateGroup are killed with the
+// task.
+func groupTaskFunc(fn func(p *pipe.State, g *stateGroup) error) pipe.Pipe {
+	return func(p *pipe.State) error {
+		return p.AddTask(&groupTask{fn: fn})
+	}
+}
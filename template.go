@@ -0,0 +1,204 @@
+package nx
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+
+	"gopkg.in/pipe.v2"
+)
+
+// TemplateOptions configures TemplateWith.
+type TemplateOptions struct {
+	// JSON causes input to be decoded as a stream of JSON values, as by
+	// nxjson.Decode, each of which is rendered.  Numbers are decoded as
+	// json.Number.
+	JSON bool
+
+	// FieldDelim separates the fields of lines.  If FieldDelim is empty fields
+	// are separated by runs of spaces and tabs.
+	FieldDelim string
+
+	// Lines determines how input is split into lines.
+	Lines LineOptions
+}
+
+// TemplateLine is the data given to a template for each line of input.
+type TemplateLine struct {
+	Text   string   // the line, without its terminator
+	NR     int      // the line number, starting from 1
+	Fields []string // the fields of the line
+}
+
+// Template returns a pipe.Pipe that renders the text/template tmpl for each
+// line read from stdin and writes the result to stdout, followed by the line's
+// terminator.  Each line is given to the template as a TemplateLine.
+// Templates may call the following functions in addition to the text/template
+// builtins.
+//
+//	field n s          the nth blank separated field of s, negative n counts from the end
+//	split sep s        s split around each instance of sep
+//	match pattern s    the submatches of regexp pattern in s, or nil
+//	group pattern n s  submatch n of regexp pattern in s, or ""
+//	env key            the value of key in the pipe.State Env
+//	upper s, lower s   s in upper or lower case
+//	trim s             s without leading and trailing white space
+//	join sep list      the elements of list joined by sep
+//
+// Errors parsing tmpl are returned when the pipe runs.
+func Template(tmpl string) pipe.Pipe {
+	return TemplateWith(tmpl, nil)
+}
+
+// TemplateWith returns a pipe.Pipe that renders tmpl for each line or JSON
+// record read from stdin as described by opts, see Template.  JSON records
+// are given to the template as decoded and followed by a newline.  A nil
+// opts is equivalent to a zero TemplateOptions.
+func TemplateWith(tmpl string, opts *TemplateOptions) pipe.Pipe {
+	if opts == nil {
+		opts = &TemplateOptions{}
+	}
+	opt := *opts
+	rc := &regexpCache{res: make(map[string]*regexp.Regexp)}
+	t, perr := template.New("nx").Funcs(templateFuncs(rc, nil)).Parse(tmpl)
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		if perr != nil {
+			return perr
+		}
+		t, err := t.Clone()
+		if err != nil {
+			return err
+		}
+		t.Funcs(templateFuncs(rc, p.Env))
+		w := bufio.NewWriter(p.Stdout)
+		if opt.JSON {
+			err = renderJSON(w, t, p.Stdin)
+		} else {
+			err = renderLines(w, t, p.Stdin, &opt)
+		}
+		if err != nil {
+			return err
+		}
+		return w.Flush()
+	})
+}
+
+func renderLines(w *bufio.Writer, t *template.Template, r io.Reader, opt *TemplateOptions) error {
+	fopt := &FieldOptions{Delim: opt.FieldDelim}
+	lr := newLineReader(r, opt.Lines)
+	for nr := 1; ; nr++ {
+		line, term, err := lr.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		data := &TemplateLine{Text: string(line), NR: nr}
+		for _, field := range fopt.split(line) {
+			data.Fields = append(data.Fields, string(field))
+		}
+		err = t.Execute(w, data)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(term)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func renderJSON(w *bufio.Writer, t *template.Template, r io.Reader) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	for {
+		var v interface{}
+		err := dec.Decode(&v)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = t.Execute(w, v)
+		if err != nil {
+			return err
+		}
+		err = w.WriteByte('\n')
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// regexpCache holds the patterns compiled by template functions.
+type regexpCache struct {
+	mu  sync.Mutex
+	res map[string]*regexp.Regexp
+}
+
+func (c *regexpCache) compile(pattern string) (*regexp.Regexp, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if re, ok := c.res[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	c.res[pattern] = re
+	return re, nil
+}
+
+// templateFuncs returns the functions available to templates.  Env is
+// consulted by the env function.
+func templateFuncs(rc *regexpCache, env []string) template.FuncMap {
+	match := func(pattern, s string) ([]string, error) {
+		re, err := rc.compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		return re.FindStringSubmatch(s), nil
+	}
+	return template.FuncMap{
+		"field": func(n int, s string) string {
+			ranges := []FieldRange{Field(n)}
+			fields := selectFields(nil, (&FieldOptions{}).split([]byte(s)), ranges)
+			if n == 0 || len(fields) == 0 {
+				return ""
+			}
+			return string(fields[0])
+		},
+		"split": func(sep, s string) []string {
+			return strings.Split(s, sep)
+		},
+		"match": match,
+		"group": func(pattern string, n int, s string) (string, error) {
+			groups, err := match(pattern, s)
+			if err != nil || n < 0 || n >= len(groups) {
+				return "", err
+			}
+			return groups[n], nil
+		},
+		"env": func(key string) string {
+			for i := len(env) - 1; i >= 0; i-- {
+				if strings.HasPrefix(env[i], key+"=") {
+					return env[i][len(key)+1:]
+				}
+			}
+			return ""
+		},
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+		"trim":  strings.TrimSpace,
+		"join": func(sep string, list []string) string {
+			return strings.Join(list, sep)
+		},
+	}
+}
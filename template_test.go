@@ -0,0 +1,54 @@
+package nx
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gopkg.in/pipe.v2"
+)
+
+func TestTemplate(t *testing.T) {
+	for i, test := range []struct {
+		tmpl    string
+		opt     TemplateOptions
+		in, out string
+	}{
+		{`{{.NR}}: {{upper .Text}}`, TemplateOptions{}, "a b\nc", "1: A B\n2: C"},
+		{`{{field 2 .Text}}|{{field -1 .Text}}|{{field 9 .Text}}`, TemplateOptions{}, "a b c\n", "b|c|\n"},
+		{`{{index .Fields 1}}`, TemplateOptions{FieldDelim: ","}, "a,b c,d\n", "b c\n"},
+		{`{{join "-" (split "," .Text)}}`, TemplateOptions{}, "a,b,c\n", "a-b-c\n"},
+		{`{{group "(\\d+)-(\\d+)" 2 .Text}}`, TemplateOptions{}, "x 12-34\nnone\n", "34\n\n"},
+		{`{{with match "^(\\w+)=(.*)$" .Text}}{{index . 2}}{{end}}`, TemplateOptions{}, "k=v\n", "v\n"},
+		{`{{.name}} {{.n}}`, TemplateOptions{JSON: true}, `{"name":"a","n":1.50} {"name":"b","n":2}`, "a 1.50\nb 2\n"},
+		{`{{.Text}}`, TemplateOptions{Lines: NULLines}, "a\x00b\x00", "a\x00b\x00"},
+	} {
+		out, err := runPipe(TemplateWith(test.tmpl, &test.opt), test.in)
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+		}
+		if out != test.out {
+			t.Errorf("test %d: output %q (expected %q)", i, out, test.out)
+		}
+	}
+
+	var out bytes.Buffer
+	s := newState(strings.NewReader("x\n"), &out)
+	s.Env = []string{"HOME=/a", "HOME=/b"}
+	err := runTimeout(Template(`{{env "HOME"}}{{env "MISSING"}}/{{.Text}}`), s)
+	if err != nil || out.String() != "/b/x\n" {
+		t.Errorf("output %q, error %v", out.String(), err)
+	}
+
+	if _, err := runPipe(Template(`{{.Text`), "a\n"); err == nil {
+		t.Errorf("invalid template accepted")
+	}
+	if _, err := runPipe(Template(`{{match "(" .Text}}`), "a\n"); err == nil {
+		t.Errorf("invalid regexp accepted")
+	}
+
+	got, err := runPipe(pipe.Line(pipe.Print("a\nb\n"), Template(`<{{.Text}}>`), Tac(nil)), "")
+	if err != nil || got != "<b>\n<a>\n" {
+		t.Errorf("line: output %q, error %v", got, err)
+	}
+}
@@ -0,0 +1,331 @@
+package nx
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"unicode"
+	"unicode/utf8"
+
+	"gopkg.in/pipe.v2"
+)
+
+// wideRanges are the East Asian Wide and Fullwidth ranges of Unicode, which
+// occupy two columns on a terminal.
+var wideRanges = []struct{ lo, hi rune }{
+	{0x1100, 0x115F},
+	{0x231A, 0x231B},
+	{0x2329, 0x232A},
+	{0x2E80, 0x303E},
+	{0x3041, 0x33FF},
+	{0x3400, 0x4DBF},
+	{0x4E00, 0x9FFF},
+	{0xA000, 0xA4CF},
+	{0xA960, 0xA97F},
+	{0xAC00, 0xD7A3},
+	{0xF900, 0xFAFF},
+	{0xFE10, 0xFE19},
+	{0xFE30, 0xFE6F},
+	{0xFF00, 0xFF60},
+	{0xFFE0, 0xFFE6},
+	{0x1F300, 0x1F64F},
+	{0x1F900, 0x1F9FF},
+	{0x20000, 0x2FFFD},
+	{0x30000, 0x3FFFD},
+}
+
+// runeWidth returns the number of columns r occupies on a terminal.
+func runeWidth(r rune) int {
+	switch {
+	case r < 0x20 || r >= 0x7f && r < 0xa0:
+		return 0
+	case r < 0x300:
+		return 1
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	}
+	for _, w := range wideRanges {
+		if r < w.lo {
+			return 1
+		}
+		if r <= w.hi {
+			return 2
+		}
+	}
+	return 1
+}
+
+// advance returns the column following r written at col.
+func advance(col int, r rune, tabWidth int) int {
+	switch r {
+	case '\t':
+		return (col/tabWidth + 1) * tabWidth
+	case '\b':
+		if col > 0 {
+			return col - 1
+		}
+		return 0
+	}
+	return col + runeWidth(r)
+}
+
+const (
+	defaultTabWidth  = 8
+	defaultFoldWidth = 80
+	defaultFmtWidth  = 75
+)
+
+// Expand returns a pipe.Pipe that replaces tabs in lines read from stdin with
+// spaces up to the next tab stop, like expand(1).  Tab stops are every
+// tabWidth columns, or 8 if tabWidth is not positive.  Columns account for
+// wide runes.
+func Expand(tabWidth int) pipe.Pipe {
+	if tabWidth <= 0 {
+		tabWidth = defaultTabWidth
+	}
+	return func(p *pipe.State) error {
+		var buf []byte
+		return Replace(func(line []byte) []byte {
+			buf = buf[:0]
+			col := 0
+			for len(line) > 0 {
+				r, size := utf8.DecodeRune(line)
+				next := advance(col, r, tabWidth)
+				if r == '\t' {
+					buf = append(buf, bytes.Repeat([]byte{' '}, next-col)...)
+				} else {
+					buf = append(buf, line[:size]...)
+				}
+				col = next
+				line = line[size:]
+			}
+			return buf
+		})(p)
+	}
+}
+
+// Unexpand returns a pipe.Pipe that replaces runs of spaces in lines read from
+// stdin with tabs where they reach a tab stop, like unexpand(1).  Only leading
+// blanks are converted unless all is true.  Tab stops are every tabWidth
+// columns, or 8 if tabWidth is not positive.
+func Unexpand(tabWidth int, all bool) pipe.Pipe {
+	if tabWidth <= 0 {
+		tabWidth = defaultTabWidth
+	}
+	return func(p *pipe.State) error {
+		var buf []byte
+		return Replace(func(line []byte) []byte {
+			buf = buf[:0]
+			col, pending := 0, 0
+			leading := true
+			for len(line) > 0 {
+				r, size := utf8.DecodeRune(line)
+				if (leading || all) && (r == ' ' || r == '\t') {
+					col = advance(col, r, tabWidth)
+					if r == '\t' {
+						buf = append(buf, '\t')
+						pending = 0
+					} else {
+						pending++
+					}
+					if pending > 0 && col%tabWidth == 0 {
+						if pending > 1 {
+							buf = append(buf, '\t')
+						} else {
+							buf = append(buf, ' ')
+						}
+						pending = 0
+					}
+					line = line[size:]
+					continue
+				}
+				buf = append(buf, bytes.Repeat([]byte{' '}, pending)...)
+				pending = 0
+				leading = false
+				buf = append(buf, line[:size]...)
+				col = advance(col, r, tabWidth)
+				line = line[size:]
+			}
+			return append(buf, bytes.Repeat([]byte{' '}, pending)...)
+		})(p)
+	}
+}
+
+// Fold returns a pipe.Pipe that wraps lines read from stdin so that no line
+// is wider than width columns, like fold(1).  If width is not positive 80 is
+// used.  Columns account for wide runes and tabs.  If atSpaces is true lines
+// are broken after the last blank that fits, when there is one, like
+// `fold -s`.
+func Fold(width int, atSpaces bool) pipe.Pipe {
+	if width <= 0 {
+		width = defaultFoldWidth
+	}
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		r := newLineReader(p.Stdin, LineOptions{})
+		w := bufio.NewWriter(p.Stdout)
+		for {
+			line, term, err := r.next()
+			if err == io.EOF {
+				return w.Flush()
+			}
+			if err != nil {
+				return err
+			}
+			err = foldLine(w, line, width, atSpaces)
+			if err == nil {
+				_, err = w.Write(term)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	})
+}
+
+func foldLine(w *bufio.Writer, line []byte, width int, atSpaces bool) error {
+	start, col, lastSpace := 0, 0, -1
+	for i := 0; i < len(line); {
+		r, size := utf8.DecodeRune(line[i:])
+		next := advance(col, r, defaultTabWidth)
+		if next > width && col > 0 {
+			end := i
+			if atSpaces && lastSpace >= start {
+				end = lastSpace + 1
+			}
+			w.Write(line[start:end])
+			err := w.WriteByte('\n')
+			if err != nil {
+				return err
+			}
+			start, col, lastSpace = end, 0, -1
+			for _, r := range string(line[start:i]) {
+				col = advance(col, r, defaultTabWidth)
+			}
+			continue
+		}
+		if r == ' ' || r == '\t' {
+			lastSpace = i
+		}
+		col = next
+		i += size
+	}
+	_, err := w.Write(line[start:])
+	return err
+}
+
+// Fmt returns a pipe.Pipe that fills paragraphs read from stdin so that lines
+// are at most width columns wide, like fmt(1).  If width is not positive 75 is
+// used.  Paragraphs are separated by blank lines, which are preserved, and
+// words are separated by single spaces.  Every line of a paragraph is
+// indented like its first line.  Words wider than width occupy a line of
+// their own.
+func Fmt(width int) pipe.Pipe {
+	if width <= 0 {
+		width = defaultFmtWidth
+	}
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		f := &filler{w: bufio.NewWriter(p.Stdout), width: width}
+		r := newLineReader(p.Stdin, LineOptions{})
+		for {
+			line, _, err := r.next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if len(bytes.TrimSpace(line)) == 0 {
+				err = f.flush()
+				if err == nil {
+					err = f.w.WriteByte('\n')
+				}
+			} else {
+				err = f.add(line)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		err := f.flush()
+		if err != nil {
+			return err
+		}
+		return f.w.Flush()
+	})
+}
+
+// filler fills the lines of a paragraph for Fmt.
+type filler struct {
+	w      *bufio.Writer
+	width  int
+	indent []byte
+	inPara bool
+	line   []byte // the line being filled, empty when it holds no words
+	col    int
+}
+
+func (f *filler) add(line []byte) error {
+	if !f.inPara {
+		f.inPara = true
+		f.indent = append(f.indent[:0], line[:len(line)-len(bytes.TrimLeft(line, " \t"))]...)
+	}
+	for _, word := range bytes.Fields(line) {
+		wordWidth := 0
+		for _, r := range string(word) {
+			wordWidth = advance(wordWidth, r, defaultTabWidth)
+		}
+		if len(f.line) > 0 && f.col+1+wordWidth > f.width {
+			err := f.writeLine()
+			if err != nil {
+				return err
+			}
+		}
+		if len(f.line) == 0 {
+			f.line = append(f.line, f.indent...)
+			f.col = 0
+			for _, r := range string(f.indent) {
+				f.col = advance(f.col, r, defaultTabWidth)
+			}
+		} else {
+			f.line = append(f.line, ' ')
+			f.col++
+		}
+		f.line = append(f.line, word...)
+		f.col += wordWidth
+	}
+	return nil
+}
+
+func (f *filler) writeLine() error {
+	f.line = append(f.line, '\n')
+	_, err := f.w.Write(f.line)
+	f.line = f.line[:0]
+	return err
+}
+
+// flush ends the current paragraph.
+func (f *filler) flush() error {
+	f.inPara = false
+	if len(f.line) == 0 {
+		return nil
+	}
+	return f.writeLine()
+}
+
+// Rev returns a pipe.Pipe that reverses the runes of each line read from
+// stdin, like rev(1).  Bytes that are not valid UTF-8 are reversed
+// individually.
+func Rev(opts ...LineOptions) pipe.Pipe {
+	return func(p *pipe.State) error {
+		var buf []byte
+		return Replace(func(line []byte) []byte {
+			buf = append(buf[:0], line...)
+			for i := len(line); i > 0; {
+				_, size := utf8.DecodeLastRune(line[:i])
+				copy(buf[len(line)-i:], line[i-size:i])
+				i -= size
+			}
+			return buf
+		}, opts...)(p)
+	}
+}
@@ -0,0 +1,138 @@
+package nx
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/pipe.v2"
+)
+
+func TestTr(t *testing.T) {
+	for i, test := range []struct {
+		set1, set2 string
+		opt        TrOptions
+		in, out    string
+	}{
+		{"abc", "xyz", TrOptions{}, "aabbcd", "xxyyzd"},
+		{"a-e", "A", TrOptions{}, "abcdef", "AAAAAf"},
+		{"[:lower:]", "[:upper:]", TrOptions{}, "héllo wörld", "HÉLLO WÖRLD"},
+		{"[:digit:]", "", TrOptions{Delete: true}, "a1b22c", "abc"},
+		{"[:space:]", "", TrOptions{Squeeze: true}, "a  b\n\n\tc", "a b\n\tc"},
+		{"a-z", "\\n", TrOptions{Complement: true, Squeeze: true}, "one, two  three", "one\ntwo\nthree"},
+		{"a-z", "", TrOptions{Complement: true, Delete: true}, "a1-b", "ab"},
+		{"a", "", TrOptions{Delete: true, Squeeze: true}, "", ""},
+		{"\\-x", "_y", TrOptions{}, "a-x\xff", "a_y\xff"},
+		{"lo", "ol", TrOptions{Squeeze: true}, "hello", "heol"},
+	} {
+		out, err := runPipe(Tr(test.set1, test.set2, &test.opt), test.in)
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+		}
+		if out != test.out {
+			t.Errorf("test %d: output %q (expected %q)", i, out, test.out)
+		}
+	}
+	if _, err := runPipe(Tr("[:bogus:]", "x", nil), "a"); err == nil {
+		t.Errorf("invalid class accepted")
+	}
+	if _, err := runPipe(Tr("abc", "", nil), "a"); err == nil {
+		t.Errorf("missing second set accepted")
+	}
+}
+
+func TestExpand(t *testing.T) {
+	for i, test := range []struct {
+		p       pipe.Pipe
+		in, out string
+	}{
+		{Expand(0), "a\tb\n\tc", "a       b\n        c"},
+		{Expand(4), "ab\tc\t\td", "ab  c       d"},
+		{Expand(4), "世\tx", "世  x"},
+		{Unexpand(4, false), "        a    b", "\t\ta    b"},
+		{Unexpand(4, true), "        a   b  c", "\t\ta\tb  c"},
+		{Unexpand(4, false), "   \tx", "\tx"},
+		{Unexpand(4, false), "   x", "   x"},
+	} {
+		out, err := runPipe(test.p, test.in)
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+		}
+		if out != test.out {
+			t.Errorf("test %d: output %q (expected %q)", i, out, test.out)
+		}
+	}
+}
+
+func TestFold(t *testing.T) {
+	for i, test := range []struct {
+		p       pipe.Pipe
+		in, out string
+	}{
+		{Fold(4, false), "abcdefghij\nxy\n", "abcd\nefgh\nij\nxy\n"},
+		{Fold(6, true), "aa bb cc dd", "aa bb \ncc dd"},
+		{Fold(6, true), "abcdefgh ij", "abcdef\ngh ij"},
+		{Fold(5, false), "世界世界", "世界\n世界"},
+		{Fmt(10), "one two three four\nfive\n\n  six seven eight\n", "one two\nthree four\nfive\n\n  six\n  seven\n  eight\n"},
+		{Fmt(5), "abcdefgh ij", "abcdefgh\nij\n"},
+		{Rev(), "abc\nhé世\n", "cba\n世éh\n"},
+		{Rev(NULLines), "ab\x00cd", "ba\x00dc"},
+	} {
+		out, err := runPipe(test.p, test.in)
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+		}
+		if out != test.out {
+			t.Errorf("test %d: output %q (expected %q)", i, out, test.out)
+		}
+	}
+}
+
+func TestTac(t *testing.T) {
+	out, err := runPipe(Tac(nil), "a\nb\nc")
+	if err != nil || out != "cb\na\n" {
+		t.Errorf("output %q, error %v", out, err)
+	}
+
+	dir, err := ioutil.TempDir("", "nx-tac-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var in, expect []string
+	for i := 0; i < 1000; i++ {
+		in = append(in, fmt.Sprintln(i))
+		expect = append([]string{fmt.Sprintln(i)}, expect...)
+	}
+	opt := &TacOptions{MemoryLimit: 1000, TempDir: dir}
+	out, err = runPipe(Tac(opt), strings.Join(in, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != strings.Join(expect, "") {
+		t.Errorf("spilled output mismatch")
+	}
+	left, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(left) != 0 {
+		t.Errorf("temporary files remain: %q", left)
+	}
+}
+
+func TestText_line(t *testing.T) {
+	out, err := runPipe(pipe.Line(
+		pipe.Print("ab\tc\nde\n"),
+		Expand(4),
+		Tr("a-z", "A-Z", nil),
+		Rev(),
+		Tac(nil),
+		Fold(2, false),
+		Fmt(80),
+		Unexpand(4, true),
+	), "")
+	if err != nil || out != "ED C B A\n" {
+		t.Errorf("output %q, error %v", out, err)
+	}
+}
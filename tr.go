@@ -0,0 +1,284 @@
+package nx
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gopkg.in/pipe.v2"
+)
+
+// TrOptions configures Tr.  The zero value translates characters in the first
+// set to the corresponding characters in the second.
+type TrOptions struct {
+	// Delete deletes characters in the first set instead of translating
+	// them, like `tr -d`.
+	Delete bool
+
+	// Squeeze replaces each run of a repeated character in the last set given
+	// with a single occurrence, like `tr -s`.  When Delete is set the second
+	// set lists the characters that are squeezed.
+	Squeeze bool
+
+	// Complement uses the characters not in the first set, like `tr -c`.
+	// When translating, every character not in the first set is replaced by
+	// the last character of the second set.
+	Complement bool
+}
+
+// trClasses are the character classes that may appear in sets given to Tr.
+// Their ASCII members determine the order of characters when translating.
+var trClasses = map[string]func(r rune) bool{
+	"alnum":  func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) },
+	"alpha":  unicode.IsLetter,
+	"blank":  func(r rune) bool { return r == ' ' || r == '\t' },
+	"cntrl":  unicode.IsControl,
+	"digit":  unicode.IsDigit,
+	"graph":  func(r rune) bool { return unicode.IsGraphic(r) && !unicode.IsSpace(r) },
+	"lower":  unicode.IsLower,
+	"print":  unicode.IsPrint,
+	"punct":  func(r rune) bool { return unicode.IsPunct(r) || unicode.IsSymbol(r) },
+	"space":  unicode.IsSpace,
+	"upper":  unicode.IsUpper,
+	"xdigit": func(r rune) bool { return r < utf8.RuneSelf && strings.ContainsRune("0123456789abcdefABCDEF", r) },
+}
+
+// trSet is a parsed set of characters.
+type trSet struct {
+	runes   []rune              // members in order, classes contribute their ASCII members
+	classes []func(r rune) bool // classes, which also match non-ASCII characters
+	names   []string
+}
+
+// parseTrSet parses a set in the syntax of tr(1).  Sets may contain ranges
+// such as "a-z", the escapes \n, \t, \r, \\ and \-, and classes such as
+// "[:alpha:]".
+func parseTrSet(s string) (*trSet, error) {
+	set := &trSet{}
+	rs := []rune(s)
+	next := func(i int) (rune, int) {
+		if rs[i] != '\\' || i+1 == len(rs) {
+			return rs[i], i + 1
+		}
+		switch rs[i+1] {
+		case 'n':
+			return '\n', i + 2
+		case 't':
+			return '\t', i + 2
+		case 'r':
+			return '\r', i + 2
+		case '0':
+			return 0, i + 2
+		}
+		return rs[i+1], i + 2
+	}
+	for i := 0; i < len(rs); {
+		if rs[i] == '[' && i+1 < len(rs) && rs[i+1] == ':' {
+			end := strings.Index(string(rs[i+2:]), ":]")
+			if end >= 0 {
+				name := string(rs[i+2:])[:end]
+				class, ok := trClasses[name]
+				if !ok {
+					return nil, fmt.Errorf("invalid character class %q", name)
+				}
+				set.classes = append(set.classes, class)
+				set.names = append(set.names, name)
+				set.runes = append(set.runes, classRunes(name)...)
+				i += 2 + len([]rune(name)) + 2
+				continue
+			}
+		}
+		lo, j := next(i)
+		if j+1 < len(rs) && rs[j] == '-' {
+			hi, k := next(j + 1)
+			if hi < lo {
+				return nil, fmt.Errorf("invalid range %q", string(rs[i:k]))
+			}
+			for r := lo; r <= hi; r++ {
+				set.runes = append(set.runes, r)
+			}
+			i = k
+			continue
+		}
+		set.runes = append(set.runes, lo)
+		i = j
+	}
+	return set, nil
+}
+
+func (set *trSet) contains(r rune) bool {
+	for _, class := range set.classes {
+		if class(r) {
+			return true
+		}
+	}
+	for _, m := range set.runes {
+		if m == r {
+			return true
+		}
+	}
+	return false
+}
+
+// only returns true if set consists of the single class name.
+func (set *trSet) only(name string) bool {
+	return len(set.names) == 1 && set.names[0] == name && len(set.runes) == len(classRunes(name))
+}
+
+func classRunes(name string) []rune {
+	var rs []rune
+	for r := rune(0); r < utf8.RuneSelf; r++ {
+		if trClasses[name](r) {
+			rs = append(rs, r)
+		}
+	}
+	return rs
+}
+
+// Tr returns a pipe.Pipe that translates, deletes or squeezes characters read
+// from stdin and writes the result to stdout, like tr(1).  Characters in set1
+// are replaced by the corresponding characters in set2, which is extended with
+// its last character if it is shorter.  Sets use the syntax of tr(1),
+// including ranges, escapes and classes such as [:space:].  Classes match
+// Unicode characters but only their ASCII members take part in translation,
+// except that [:lower:] and [:upper:] translate to each other with Unicode
+// case mapping.  Bytes that are not valid UTF-8 are copied unchanged.  A nil
+// opts is equivalent to a zero TrOptions.
+func Tr(set1, set2 string, opts *TrOptions) pipe.Pipe {
+	if opts == nil {
+		opts = &TrOptions{}
+	}
+	opt := *opts
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		t, err := newTranslator(set1, set2, &opt)
+		if err != nil {
+			return err
+		}
+		return t.translate(p.Stdin, p.Stdout)
+	})
+}
+
+type translator struct {
+	opt     *TrOptions
+	from    *trSet
+	squeeze func(r rune) bool
+	mapping map[rune]rune
+	mapFunc func(r rune) rune
+
+	complement bool // replace characters in the complement of from with last
+	last       rune
+}
+
+func newTranslator(set1, set2 string, opt *TrOptions) (*translator, error) {
+	from, err := parseTrSet(set1)
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseTrSet(set2)
+	if err != nil {
+		return nil, err
+	}
+	t := &translator{opt: opt, from: from}
+	translate := !opt.Delete && set2 != ""
+	switch {
+	case opt.Squeeze && (opt.Delete || translate):
+		t.squeeze = to.contains
+	case opt.Squeeze:
+		t.squeeze = t.selected
+	}
+	if !translate {
+		if !opt.Delete && !opt.Squeeze {
+			return nil, fmt.Errorf("missing second set")
+		}
+		return t, nil
+	}
+	if len(to.runes) == 0 {
+		return nil, fmt.Errorf("empty second set")
+	}
+	switch {
+	case opt.Complement:
+		t.complement = true
+		t.last = to.runes[len(to.runes)-1]
+	case from.only("lower") && to.only("upper"):
+		t.mapFunc = unicode.ToUpper
+	case from.only("upper") && to.only("lower"):
+		t.mapFunc = unicode.ToLower
+	default:
+		t.mapping = make(map[rune]rune, len(from.runes))
+		for i, r := range from.runes {
+			j := i
+			if j >= len(to.runes) {
+				j = len(to.runes) - 1
+			}
+			t.mapping[r] = to.runes[j]
+		}
+	}
+	return t, nil
+}
+
+// selected returns true if r is in the first set, or its complement.
+func (t *translator) selected(r rune) bool {
+	return t.from.contains(r) != t.opt.Complement
+}
+
+// apply returns the replacement for r and false if r is deleted.
+func (t *translator) apply(r rune) (rune, bool) {
+	switch {
+	case t.opt.Delete:
+		return r, !t.selected(r)
+	case t.mapFunc != nil:
+		if t.from.contains(r) {
+			return t.mapFunc(r), true
+		}
+	case t.mapping != nil:
+		if m, ok := t.mapping[r]; ok {
+			return m, true
+		}
+	case t.complement:
+		if t.selected(r) {
+			return t.last, true
+		}
+	}
+	return r, true
+}
+
+func (t *translator) translate(r io.Reader, w io.Writer) error {
+	br := bufio.NewReader(r)
+	bw := bufio.NewWriter(w)
+	prev, squeezing := rune(-1), false
+	for {
+		c, size, err := br.ReadRune()
+		if err == io.EOF {
+			return bw.Flush()
+		}
+		if err != nil {
+			return err
+		}
+		if c == utf8.RuneError && size == 1 {
+			br.UnreadRune()
+			b, _ := br.ReadByte()
+			err = bw.WriteByte(b)
+			if err != nil {
+				return err
+			}
+			prev, squeezing = -1, false
+			continue
+		}
+		c, keep := t.apply(c)
+		if !keep {
+			continue
+		}
+		if t.squeeze != nil && squeezing && c == prev {
+			continue
+		}
+		prev = c
+		squeezing = t.squeeze != nil && t.squeeze(c)
+		_, err = bw.WriteRune(c)
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package nx
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+
+	"gopkg.in/pipe.v2"
+)
+
+// UniqOptions configures Uniq.  The zero value removes adjacent duplicate
+// lines.
+type UniqOptions struct {
+	// Count prefixes each line with the number of times it occurred, like
+	// `uniq -c`.
+	Count bool
+
+	// Repeated emits only lines that occur more than once, like `uniq -d`.
+	Repeated bool
+
+	// Unique emits only lines that occur once, like `uniq -u`.
+	Unique bool
+
+	// SkipFields is the number of leading fields ignored when comparing
+	// lines.  Fields are blanks followed by non-blank characters.
+	SkipFields int
+
+	// SkipChars is the number of bytes ignored when comparing lines, after
+	// fields are skipped.
+	SkipChars int
+
+	// IgnoreCase compares lines without regard to case.
+	IgnoreCase bool
+
+	// Lines determines how input is split into lines.
+	Lines LineOptions
+}
+
+// compareKey returns the portion of line compared by Uniq.
+func (opt *UniqOptions) compareKey(line []byte) []byte {
+	for i := 0; i < opt.SkipFields; i++ {
+		line = bytes.TrimLeft(line, " \t")
+		j := bytes.IndexAny(line, " \t")
+		if j < 0 {
+			return nil
+		}
+		line = line[j:]
+	}
+	if opt.SkipChars >= len(line) {
+		return nil
+	}
+	return line[opt.SkipChars:]
+}
+
+func (opt *UniqOptions) equal(a, b []byte) bool {
+	a, b = opt.compareKey(a), opt.compareKey(b)
+	if opt.IgnoreCase {
+		return bytes.EqualFold(a, b)
+	}
+	return bytes.Equal(a, b)
+}
+
+// Uniq returns a pipe.Pipe that emits lines read from stdin to stdout,
+// omitting adjacent lines that are equal, like uniq(1).  The first of each
+// group of equal lines is emitted.  A nil opts is equivalent to a zero
+// UniqOptions.
+func Uniq(opts *UniqOptions) pipe.Pipe {
+	if opts == nil {
+		opts = &UniqOptions{}
+	}
+	opt := *opts
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		var prev, prevTerm []byte
+		var count int
+		w := bufio.NewWriter(p.Stdout)
+		emit := func() error {
+			if count == 0 || opt.Repeated && count == 1 || opt.Unique && count > 1 {
+				return nil
+			}
+			if opt.Count {
+				_, err := fmt.Fprintf(w, "%7d ", count)
+				if err != nil {
+					return err
+				}
+			}
+			_, err := w.Write(prev)
+			if err == nil {
+				_, err = w.Write(prevTerm)
+			}
+			return err
+		}
+		r := newLineReader(p.Stdin, opt.Lines)
+		for {
+			line, term, err := r.next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if count > 0 && opt.equal(prev, line) {
+				count++
+				continue
+			}
+			err = emit()
+			if err != nil {
+				return err
+			}
+			prev = append(prev[:0], line...)
+			prevTerm = append(prevTerm[:0], term...)
+			count = 1
+		}
+		err := emit()
+		if err != nil {
+			return err
+		}
+		return w.Flush()
+	})
+}
+
+// ErrDistinctMemory is returned by Distinct when the lines it has seen do not
+// fit in DistinctOptions.MaxMemory.
+var ErrDistinctMemory = errors.New("nx: distinct lines exceed memory limit")
+
+// DistinctOptions configures Distinct.
+type DistinctOptions struct {
+	// MaxMemory is the approximate number of bytes used to remember lines.
+	// If MaxMemory is zero 64MB is used.
+	MaxMemory int64
+
+	// Bloom uses a bloom filter occupying MaxMemory bytes instead of an exact
+	// set of lines.  Memory use does not grow with input but lines may be
+	// falsely considered duplicates, more frequently as the filter fills.
+	Bloom bool
+
+	// IgnoreCase compares lines without regard to case.
+	IgnoreCase bool
+
+	// Lines determines how input is split into lines.
+	Lines LineOptions
+}
+
+const (
+	defaultDistinctMemory = 64 << 20
+	distinctLineOverhead  = 48 // approximate memory used by each set entry in addition to its content
+	bloomHashes           = 5
+)
+
+// Distinct returns a pipe.Pipe that emits the first occurrence of each line
+// read from stdin to stdout, regardless of whether duplicates are adjacent.
+// Lines are emitted in the order they are read.  A nil opts is equivalent to a
+// zero DistinctOptions.
+func Distinct(opts *DistinctOptions) pipe.Pipe {
+	if opts == nil {
+		opts = &DistinctOptions{}
+	}
+	opt := *opts
+	if opt.MaxMemory <= 0 {
+		opt.MaxMemory = defaultDistinctMemory
+	}
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		var seen func(line []byte) (bool, error)
+		if opt.Bloom {
+			seen = newBloomFilter(opt.MaxMemory).testAndAdd
+		} else {
+			set := make(map[string]struct{})
+			var size int64
+			seen = func(line []byte) (bool, error) {
+				if _, ok := set[string(line)]; ok {
+					return true, nil
+				}
+				size += int64(len(line)) + distinctLineOverhead
+				if size > opt.MaxMemory {
+					return false, ErrDistinctMemory
+				}
+				set[string(line)] = struct{}{}
+				return false, nil
+			}
+		}
+
+		w := bufio.NewWriter(p.Stdout)
+		r := newLineReader(p.Stdin, opt.Lines)
+		for {
+			line, term, err := r.next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			key := line
+			if opt.IgnoreCase {
+				key = bytes.ToLower(line)
+			}
+			dup, err := seen(key)
+			if err != nil {
+				return err
+			}
+			if dup {
+				continue
+			}
+			_, err = w.Write(line)
+			if err == nil {
+				_, err = w.Write(term)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return w.Flush()
+	})
+}
+
+// bloomFilter is a fixed size bloom filter using double hashing.
+type bloomFilter struct {
+	bits []uint64
+}
+
+func newBloomFilter(size int64) *bloomFilter {
+	n := size / 8
+	if n < 1 {
+		n = 1
+	}
+	return &bloomFilter{bits: make([]uint64, n)}
+}
+
+// testAndAdd adds b to the filter and returns true if it may have been added
+// previously.
+func (f *bloomFilter) testAndAdd(b []byte) (bool, error) {
+	h := fnv.New128a()
+	h.Write(b)
+	sum := h.Sum(nil)
+	var h1, h2 uint64
+	for i := 0; i < 8; i++ {
+		h1 = h1<<8 | uint64(sum[i])
+		h2 = h2<<8 | uint64(sum[8+i])
+	}
+	m := uint64(len(f.bits)) * 64
+	present := true
+	for i := uint64(0); i < bloomHashes; i++ {
+		bit := (h1 + i*h2) % m
+		word, mask := bit/64, uint64(1)<<(bit%64)
+		if f.bits[word]&mask == 0 {
+			present = false
+			f.bits[word] |= mask
+		}
+	}
+	return present, nil
+}
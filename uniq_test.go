@@ -0,0 +1,94 @@
+package nx
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"gopkg.in/pipe.v2"
+)
+
+func TestUniq(t *testing.T) {
+	in := "a\na\nb\nA\nc\nc\nc\n"
+	for i, test := range []struct {
+		opt *UniqOptions
+		in  string
+		out string
+	}{
+		{nil, in, "a\nb\nA\nc\n"},
+		{&UniqOptions{Count: true}, in, "      2 a\n      1 b\n      1 A\n      3 c\n"},
+		{&UniqOptions{Repeated: true}, in, "a\nc\n"},
+		{&UniqOptions{Unique: true}, in, "b\nA\n"},
+		{&UniqOptions{IgnoreCase: true}, "a\nA\nb", "a\nb"},
+		{&UniqOptions{SkipFields: 1}, "1 x\n2 x\n3  y\n", "1 x\n3  y\n"},
+		{&UniqOptions{SkipChars: 2}, "1 x\n2 x\n3 y\n", "1 x\n3 y\n"},
+	} {
+		out, err := runPipe(Uniq(test.opt), test.in)
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+		}
+		if out != test.out {
+			t.Errorf("test %d: %q (expected %q)", i, out, test.out)
+		}
+	}
+}
+
+func TestDistinct(t *testing.T) {
+	in := "b\na\nb\nc\nA\na\n"
+	for i, test := range []struct {
+		opt *DistinctOptions
+		out string
+		err error
+	}{
+		{nil, "b\na\nc\nA\n", nil},
+		{&DistinctOptions{IgnoreCase: true}, "b\na\nc\n", nil},
+		{&DistinctOptions{Bloom: true, MaxMemory: 1 << 10}, "b\na\nc\nA\n", nil},
+		{&DistinctOptions{MaxMemory: 100}, "b\na\n", ErrDistinctMemory},
+	} {
+		out, err := runPipe(Distinct(test.opt), in)
+		if err != test.err {
+			t.Errorf("test %d: error %v (expected %v)", i, err, test.err)
+		}
+		if err == nil && out != test.out {
+			t.Errorf("test %d: %q (expected %q)", i, out, test.out)
+		}
+	}
+}
+
+func TestDistinct_bloom(t *testing.T) {
+	var lines []string
+	for i := 0; i < 1000; i++ {
+		lines = append(lines, fmt.Sprint(i))
+	}
+	in := strings.Join(append(lines, lines...), "\n") + "\n"
+	out, err := runPipe(Distinct(&DistinctOptions{Bloom: true, MaxMemory: 4 << 10}), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := strings.Count(out, "\n")
+	if n > 1000 || n < 990 {
+		t.Errorf("%d distinct lines", n)
+	}
+}
+
+func TestCount(t *testing.T) {
+	for i, test := range []struct {
+		p       pipe.Pipe
+		in, out string
+	}{
+		{CountLines(), "a\nb\nc", "2\n"},
+		{CountLines(NULLines), "a\x00b\x00", "2\n"},
+		{CountLines(LineOptions{Delim: []byte("\r\n")}), "a\r\nb\n", "1\n"},
+		{CountBytes(), "héllo", "6\n"},
+		{CountWords(), "  hello  world\n\tfoo", "3\n"},
+		{CountWords(), "", "0\n"},
+	} {
+		out, err := runPipe(test.p, test.in)
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+		}
+		if out != test.out {
+			t.Errorf("test %d: %q (expected %q)", i, out, test.out)
+		}
+	}
+}
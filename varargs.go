@@ -0,0 +1,286 @@
+package nx
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+
+	"gopkg.in/pipe.v2"
+)
+
+// ErrUnmatchedQuote is returned by Varargs when a quoted argument is not
+// closed before the end of its line.
+var ErrUnmatchedQuote = errors.New("nx: unmatched quote in arguments")
+
+// ErrArgTooLong is returned by Varargs when a single argument exceeds
+// VarargsOptions.MaxBytes.
+var ErrArgTooLong = errors.New("nx: argument too long")
+
+// VarargsSplit determines how Varargs splits its input into arguments.
+type VarargsSplit int
+
+const (
+	// VarargsWords splits arguments on blanks and newlines.  Single and
+	// double quotes group characters including blanks into one argument and a
+	// backslash escapes the character following it, as with xargs(1).
+	VarargsWords VarargsSplit = iota
+
+	// VarargsLines treats each line as one argument, like `xargs -d '\n'`.
+	VarargsLines
+
+	// VarargsNUL treats each NUL terminated string as one argument, like
+	// `xargs -0`.
+	VarargsNUL
+)
+
+// VarargsOptions configures Varargs.  The zero value passes every word read
+// from stdin to a single call of f.
+type VarargsOptions struct {
+	// Split determines how input is split into arguments.
+	Split VarargsSplit
+
+	// MaxArgs is the maximum number of arguments in each batch, like
+	// `xargs -n`.  If MaxArgs is not positive the number is not limited.
+	MaxArgs int
+
+	// MaxBytes is the maximum size of each batch in bytes, like `xargs -s`.
+	// Each argument counts its length plus one.  If MaxBytes is not positive
+	// the size is not limited.
+	MaxBytes int
+
+	// Template, if not nil, is the list of arguments passed to f for each
+	// batch.  An element equal to Placeholder is replaced by all arguments of
+	// the batch.  Occurrences of Placeholder within other elements are
+	// replaced by the arguments of the batch separated by spaces; combined
+	// with a MaxArgs of 1 this behaves like `xargs -I`.
+	Template []string
+
+	// Placeholder is replaced by arguments in Template.  If Placeholder is
+	// empty "{}" is used.
+	Placeholder string
+
+	// SkipEmpty does not call f when there are no arguments, like
+	// `xargs -r`.  By default f is called once with no arguments.
+	SkipEmpty bool
+
+	// Parallel is the maximum number of batches run concurrently, like
+	// `xargs -P`.  The output of each concurrent batch is buffered and written
+	// once the batch completes, so batches may be emitted out of order.  If
+	// Parallel is less than 2 batches run one at a time.
+	Parallel int
+}
+
+// Varargs returns a pipe.Pipe that reads arguments from stdin and runs the
+// pipe.Pipe returned by f for each batch of arguments, like xargs(1).  Batches
+// are formed as arguments are read, so input need not fit in memory.  The
+// pipes returned by f read no input and write to stdout and stderr, and each
+// runs to completion, including its tasks, before the next batch is formed.
+// After a batch fails no more batches are started and its error is returned.
+// When multiple options are given the last is used.
+func Varargs(f func(args []string) pipe.Pipe, opts ...VarargsOptions) pipe.Pipe {
+	var opt VarargsOptions
+	if len(opts) > 0 {
+		opt = opts[len(opts)-1]
+	}
+	return groupTaskFunc(func(p *pipe.State, g *stateGroup) error {
+		var next func() (string, error)
+		switch opt.Split {
+		case VarargsLines, VarargsNUL:
+			lopt := LineOptions{}
+			if opt.Split == VarargsNUL {
+				lopt = NULLines
+			}
+			r := newLineReader(p.Stdin, lopt)
+			next = func() (string, error) {
+				line, _, err := r.next()
+				return string(line), err
+			}
+		default:
+			next = (&wordReader{r: bufio.NewReader(p.Stdin)}).next
+		}
+
+		b := &varargsBatcher{p: p, g: g, f: f, opt: &opt}
+		if opt.Parallel > 1 {
+			b.sem = make(chan struct{}, opt.Parallel)
+		}
+		var args []string
+		var size int
+		for {
+			arg, err := next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return b.wait(err)
+			}
+			if opt.MaxBytes > 0 && len(arg)+1 > opt.MaxBytes {
+				return b.wait(ErrArgTooLong)
+			}
+			if len(args) > 0 && opt.MaxBytes > 0 && size+len(arg)+1 > opt.MaxBytes {
+				if err := b.run(args); err != nil {
+					return b.wait(err)
+				}
+				args, size = nil, 0
+			}
+			args = append(args, arg)
+			size += len(arg) + 1
+			if opt.MaxArgs > 0 && len(args) >= opt.MaxArgs {
+				if err := b.run(args); err != nil {
+					return b.wait(err)
+				}
+				args, size = nil, 0
+			}
+		}
+		if len(args) > 0 || !b.ran && !opt.SkipEmpty {
+			if err := b.run(args); err != nil {
+				return b.wait(err)
+			}
+		}
+		return b.wait(nil)
+	})
+}
+
+// command returns the arguments passed to f for a batch of arguments.
+func (opt *VarargsOptions) command(args []string) []string {
+	if opt.Template == nil {
+		return args
+	}
+	ph := opt.Placeholder
+	if ph == "" {
+		ph = "{}"
+	}
+	var cmd []string
+	for _, t := range opt.Template {
+		switch {
+		case t == ph:
+			cmd = append(cmd, args...)
+		case strings.Contains(t, ph):
+			cmd = append(cmd, strings.Replace(t, ph, strings.Join(args, " "), -1))
+		default:
+			cmd = append(cmd, t)
+		}
+	}
+	return cmd
+}
+
+// varargsBatcher runs batches of arguments for Varargs.
+type varargsBatcher struct {
+	p   *pipe.State
+	g   *stateGroup
+	f   func(args []string) pipe.Pipe
+	opt *VarargsOptions
+	ran bool
+
+	sem chan struct{} // limits concurrent batches, nil when batches run serially
+	wg  sync.WaitGroup
+	mu  sync.Mutex // guards err and writes to p.Stdout and p.Stderr
+	err error
+}
+
+// run runs a batch of arguments.  When batches run concurrently run returns
+// the error of any batch that has already failed.
+func (b *varargsBatcher) run(args []string) error {
+	b.ran = true
+	cmd := b.opt.command(args)
+	if b.sem == nil {
+		return b.g.run(b.f(cmd), newSubState(b.p, nil, b.p.Stdout, b.p.Stderr))
+	}
+	b.sem <- struct{}{}
+	b.mu.Lock()
+	err := b.err
+	b.mu.Unlock()
+	if err != nil {
+		<-b.sem
+		return err
+	}
+	b.wg.Add(1)
+	go func() {
+		defer func() {
+			<-b.sem
+			b.wg.Done()
+		}()
+		stdout, stderr := &pipe.OutputBuffer{}, &pipe.OutputBuffer{}
+		err := b.g.run(b.f(cmd), newSubState(b.p, nil, stdout, stderr))
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		_, werr := b.p.Stdout.Write(stdout.Bytes())
+		if len(stderr.Bytes()) > 0 {
+			b.p.Stderr.Write(stderr.Bytes())
+		}
+		if err == nil {
+			err = werr
+		}
+		if b.err == nil {
+			b.err = err
+		}
+	}()
+	return nil
+}
+
+// wait waits for concurrent batches and returns err or, if err is nil, the
+// first error encountered by a batch.
+func (b *varargsBatcher) wait(err error) error {
+	b.wg.Wait()
+	if err != nil {
+		return err
+	}
+	return b.err
+}
+
+// wordReader splits input into words with quoting like xargs(1).
+type wordReader struct {
+	r *bufio.Reader
+}
+
+func (w *wordReader) next() (string, error) {
+	var word []byte
+	var quote byte
+	inWord := false
+	for {
+		c, err := w.r.ReadByte()
+		if err == io.EOF {
+			if quote != 0 {
+				return "", ErrUnmatchedQuote
+			}
+			if inWord {
+				return string(word), nil
+			}
+			return "", io.EOF
+		}
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case quote != 0:
+			switch c {
+			case quote:
+				quote = 0
+			case '\n':
+				return "", ErrUnmatchedQuote
+			default:
+				word = append(word, c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			inWord = true
+		case c == '\\':
+			escaped, err := w.r.ReadByte()
+			if err == nil {
+				c = escaped
+			} else if err != io.EOF {
+				return "", err
+			}
+			word = append(word, c)
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f':
+			if inWord {
+				return string(word), nil
+			}
+		default:
+			word = append(word, c)
+			inWord = true
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package nx
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"gopkg.in/pipe.v2"
+)
+
+func echoArgs(args []string) pipe.Pipe {
+	return pipe.TaskFunc(func(p *pipe.State) error {
+		_, err := fmt.Fprintf(p.Stdout, "%q\n", args)
+		return err
+	})
+}
+
+func TestVarargs(t *testing.T) {
+	for i, test := range []struct {
+		opt     VarargsOptions
+		in, out string
+		err     error
+	}{
+		{VarargsOptions{}, "a b\nc", "[\"a\" \"b\" \"c\"]\n", nil},
+		{VarargsOptions{}, "", "[]\n", nil},
+		{VarargsOptions{SkipEmpty: true}, " \n", "", nil},
+		{VarargsOptions{}, `'a b' "c d" e\ f 'g"h'`, "[\"a b\" \"c d\" \"e f\" \"g\\\"h\"]\n", nil},
+		{VarargsOptions{}, "'a b\nc'", "", ErrUnmatchedQuote},
+		{VarargsOptions{Split: VarargsLines}, "a b\n'c\n", "[\"a b\" \"'c\"]\n", nil},
+		{VarargsOptions{Split: VarargsNUL}, "a b\x00c\nd\x00", "[\"a b\" \"c\\nd\"]\n", nil},
+		{VarargsOptions{MaxArgs: 2}, "a b c d e", "[\"a\" \"b\"]\n[\"c\" \"d\"]\n[\"e\"]\n", nil},
+		{VarargsOptions{MaxBytes: 6}, "ab cd ef g", "[\"ab\" \"cd\"]\n[\"ef\" \"g\"]\n", nil},
+		{VarargsOptions{MaxBytes: 3}, "ab cde", "", ErrArgTooLong},
+		{VarargsOptions{Template: []string{"-x", "{}", "y"}, MaxArgs: 2}, "a b c", "[\"-x\" \"a\" \"b\" \"y\"]\n[\"-x\" \"c\" \"y\"]\n", nil},
+		{VarargsOptions{Template: []string{"mv", "%", "%.bak"}, Placeholder: "%", MaxArgs: 1}, "a\nb", "[\"mv\" \"a\" \"a.bak\"]\n[\"mv\" \"b\" \"b.bak\"]\n", nil},
+	} {
+		out, err := runPipe(Varargs(echoArgs, test.opt), test.in)
+		if err != test.err {
+			t.Errorf("test %d: error %v (expected %v)", i, err, test.err)
+		}
+		if out != test.out {
+			t.Errorf("test %d: output %q (expected %q)", i, out, test.out)
+		}
+	}
+}
+
+func TestVarargsParallel(t *testing.T) {
+	var in []string
+	for i := 0; i < 100; i++ {
+		in = append(in, fmt.Sprint(i))
+	}
+	opt := VarargsOptions{MaxArgs: 3, Parallel: 4}
+	out, err := runPipe(Varargs(func(args []string) pipe.Pipe {
+		return func(p *pipe.State) error {
+			for _, arg := range args {
+				fmt.Fprintln(p.Stdout, arg)
+			}
+			return nil
+		}
+	}, opt), strings.Join(in, "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Fields(out)
+	sort.Strings(lines)
+	sort.Strings(in)
+	if strings.Join(lines, " ") != strings.Join(in, " ") {
+		t.Errorf("output %q", out)
+	}
+
+	fail := fmt.Errorf("fail")
+	_, err = runPipe(Varargs(func(args []string) pipe.Pipe {
+		return func(p *pipe.State) error {
+			if args[0] == "50" {
+				return fail
+			}
+			return nil
+		}
+	}, VarargsOptions{MaxArgs: 1, Parallel: 4}), strings.Join(in, "\n"))
+	if err != fail {
+		t.Errorf("error %v (expected %v)", err, fail)
+	}
+}
+
+func TestVarargsExec(t *testing.T) {
+	echo := func(args []string) pipe.Pipe { return pipe.Exec("echo", args...) }
+	out, err := runPipe(Varargs(echo), "a b\nc")
+	if err != nil || out != "a b c\n" {
+		t.Errorf("output %q, error %v", out, err)
+	}
+	out, err = runPipe(Varargs(echo, VarargsOptions{MaxArgs: 1, Parallel: 2}), "a b")
+	if err != nil || (out != "a\nb\n" && out != "b\na\n") {
+		t.Errorf("parallel output %q, error %v", out, err)
+	}
+}